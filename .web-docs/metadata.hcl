@@ -48,13 +48,23 @@ integration {
     slug = "name"
   }
   component {
-    type = "post-processor"
-    name = "Component Name"
-    slug = "name"
+    type = "data-source"
+    name = "LetsCloud Image"
+    slug = "image"
   }
   component {
     type = "data-source"
-    name = "Component Name"
-    slug = "name"
+    name = "LetsCloud Plan"
+    slug = "plan"
+  }
+  component {
+    type = "data-source"
+    name = "LetsCloud Snapshot"
+    slug = "snapshot"
+  }
+  component {
+    type = "post-processor"
+    name = "LetsCloud Retention"
+    slug = "retention"
   }
 }
@@ -0,0 +1,116 @@
+package letscloud
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/letscloud-community/letscloud-go"
+)
+
+// defaultClientTimeout bounds every HTTP request made to the LetsCloud API.
+const defaultClientTimeout = 60 * time.Second
+
+// AccessConfig holds the settings used to reach the LetsCloud API. It is
+// shared by the builder, data sources and post-processors.
+type AccessConfig struct {
+	APIKey          string `mapstructure:"api_key"`
+	APIURL          string `mapstructure:"api_url"`          // Optional: Defaults to $LETSCLOUD_API_URL or the public API
+	Profile         string `mapstructure:"profile"`          // Optional: Defaults to $LETSCLOUD_PROFILE or "default"
+	CredentialsFile string `mapstructure:"credentials_file"` // Optional: Defaults to $LETSCLOUD_CREDENTIALS_FILE or ~/.letscloud/credentials
+}
+
+// Prepare applies defaults, resolves the API key and validates the settings.
+func (c *AccessConfig) Prepare() []error {
+	var errs []error
+
+	if c.APIURL == "" {
+		c.APIURL = os.Getenv("LETSCLOUD_API_URL")
+	}
+	// The SDK appends endpoint paths to the base URL verbatim.
+	c.APIURL = strings.TrimSuffix(c.APIURL, "/")
+
+	if c.APIURL != "" {
+		if u, err := url.Parse(c.APIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("`api_url` must be an absolute http(s) URL, got %q", c.APIURL))
+		}
+	}
+
+	if err := c.prepareAPIKey(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		// Protect the APIKey from being logged
+		packer.LogSecretFilter.Set(c.APIKey)
+	}
+
+	return errs
+}
+
+// prepareAPIKey resolves the API key from, in order, the `api_key` option,
+// the profile named by the `profile` option, the LETSCLOUD_API_KEY
+// environment variable and the profile named by LETSCLOUD_PROFILE, or the
+// default one.
+func (c *AccessConfig) prepareAPIKey() error {
+	// A profile set in the template is as explicit as an API key, so it
+	// wins over the environment.
+	explicitProfile := c.Profile != ""
+	if c.Profile == "" {
+		c.Profile = os.Getenv("LETSCLOUD_PROFILE")
+	}
+	if c.Profile == "" {
+		c.Profile = defaultProfile
+	}
+
+	if c.APIKey != "" {
+		return nil
+	}
+
+	tried := []string{"the `api_key` option"}
+	if !explicitProfile {
+		if c.APIKey = os.Getenv("LETSCLOUD_API_KEY"); c.APIKey != "" {
+			return nil
+		}
+		tried = append(tried, "the LETSCLOUD_API_KEY environment variable")
+	}
+
+	path, err := credentialsFilePath(c.CredentialsFile)
+	if err != nil {
+		return fmt.Errorf("invalid `credentials_file`: %s", err)
+	}
+
+	key, note, err := apiKeyFromProfile(path, c.Profile)
+	if err != nil {
+		return err
+	}
+	if key == "" {
+		return fmt.Errorf("`api_key` is required: tried %s and the credentials file (%s)",
+			strings.Join(tried, ", "), note)
+	}
+
+	c.APIKey = key
+	return nil
+}
+
+// Client returns a LetsCloud SDK client for the configured account and
+// endpoint.
+func (c *AccessConfig) Client() (*letscloud.LetsCloud, error) {
+	var opts []letscloud.Option
+	if c.APIURL != "" {
+		opts = append(opts, letscloud.WithBaseURL(c.APIURL))
+	}
+
+	sdkClient, err := letscloud.New(c.APIKey, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize LetsCloud client: %v", err)
+	}
+	if err := sdkClient.SetTimeout(defaultClientTimeout); err != nil {
+		return nil, fmt.Errorf("unable to initialize LetsCloud client: %v", err)
+	}
+
+	return sdkClient, nil
+}
@@ -1,15 +1,25 @@
 package letscloud
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
+	"slices"
+	"strings"
 
 	"github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
 )
 
 // Artifact implements the packer.Artifact interface.
 type Artifact struct {
 	StateData map[string]interface{}
+
+	// apiClient and keepInstance let Destroy remove what the build left
+	// in the account.
+	apiClient    *APIClient
+	keepInstance bool
 }
 
 // NewArtifact creates a new Artifact.
@@ -19,34 +29,179 @@ func NewArtifact(values map[string]interface{}) packer.Artifact {
 	}
 }
 
+// NewSnapshotArtifact creates an Artifact for a snapshot that was not made by
+// the builder, such as an imported disk image. Destroying it deletes the
+// snapshot through client.
+func NewSnapshotArtifact(client *APIClient, values map[string]interface{}) packer.Artifact {
+	return &Artifact{
+		StateData: values,
+		apiClient: client,
+	}
+}
+
 // String returns a description of the artifact.
 func (a *Artifact) String() string {
-	return fmt.Sprintf("Artifact with ID: %s", a.Id())
+	if slug := a.stateString("snapshot_slug"); slug != "" {
+		locations := a.locations()
+		noun := "location"
+		if len(locations) > 1 {
+			noun = "locations"
+		}
+		return fmt.Sprintf("A snapshot was created: '%s' (ID: %s) in %s '%s', from image '%s' on plan '%s'",
+			a.stateString("snapshot_name"), a.Id(), noun, strings.Join(locations, ", "),
+			a.stateString("source_image"), a.stateString("plan_slug"))
+	}
+	return fmt.Sprintf("No snapshot was created; instance '%s' in location '%s', from image '%s' on plan '%s'",
+		a.stateString("instance_identifier"), a.stateString("location_slug"),
+		a.stateString("source_image"), a.stateString("plan_slug"))
 }
 
-// State returns the state data of the artifact.
+// State returns the state data of the artifact, and the snapshot metadata
+// for the HCP Packer registry: one image, or one per location when the
+// snapshot was copied to several.
 func (a *Artifact) State(name string) interface{} {
+	if name == registryimage.ArtifactStateURI {
+		images := a.registryImages()
+		switch len(images) {
+		case 0:
+			return nil
+		case 1:
+			return images[0]
+		default:
+			return images
+		}
+	}
 	return a.StateData[name]
 }
 
+// registryImages describes the snapshot in each of its locations for the
+// HCP Packer registry. It returns nil when no snapshot was created.
+func (a *Artifact) registryImages() []*registryimage.Image {
+	if a.stateString("snapshot_slug") == "" {
+		return nil
+	}
+
+	// Labels are listed explicitly so that unrelated state never ends up
+	// in the registry.
+	labels := map[string]interface{}{
+		"snapshot_name": a.stateString("snapshot_name"),
+		"source_image":  a.stateString("source_image"),
+		"plan_slug":     a.stateString("plan_slug"),
+	}
+
+	slugs := a.snapshotSlugs()
+	var images []*registryimage.Image
+	for _, loc := range a.locations() {
+		img, err := registryimage.FromArtifact(a,
+			registryimage.WithProvider("letscloud"),
+			registryimage.WithID(slugs[loc]),
+			registryimage.WithRegion(loc),
+			registryimage.WithSourceID(a.stateString("source_image")),
+			registryimage.SetLabels(labels),
+		)
+		if err != nil {
+			log.Printf("[DEBUG] error encountered when creating a registry image: %s", err)
+			return nil
+		}
+		images = append(images, img)
+	}
+	return images
+}
+
+// locations returns the locations the artifact is available in, starting
+// with the one it was built in.
+func (a *Artifact) locations() []string {
+	if locations, ok := a.StateData["location_slugs"].([]string); ok && len(locations) > 0 {
+		return locations
+	}
+	return []string{a.stateString("location_slug")}
+}
+
+// snapshotSlugs returns the slug of the snapshot in each of its locations.
+func (a *Artifact) snapshotSlugs() map[string]string {
+	if slugs, ok := a.StateData["snapshot_slugs"].(map[string]string); ok && len(slugs) > 0 {
+		return slugs
+	}
+	slugs := map[string]string{}
+	if slug := a.stateString("snapshot_slug"); slug != "" {
+		for _, loc := range a.locations() {
+			slugs[loc] = slug
+		}
+	}
+	return slugs
+}
+
 // Files returns the files associated with this artifact.
 func (a *Artifact) Files() []string {
 	return nil
 }
 
-// Destroy is a no-op for this artifact.
+// Destroy deletes the snapshot from every location, and the instance if it
+// was kept.
 func (a *Artifact) Destroy() error {
-	// Implement any cleanup logic here if necessary.
+	var snapshotSlugs []string
+	for _, slug := range a.snapshotSlugs() {
+		if !slices.Contains(snapshotSlugs, slug) {
+			snapshotSlugs = append(snapshotSlugs, slug)
+		}
+	}
+	slices.Sort(snapshotSlugs)
+	instanceID := a.stateString("instance_identifier")
+	if !a.keepInstance {
+		instanceID = ""
+	}
+	if len(snapshotSlugs) == 0 && instanceID == "" {
+		return nil
+	}
+	if a.apiClient == nil {
+		return fmt.Errorf("unable to destroy artifact %s: no LetsCloud API client", a.Id())
+	}
+
+	ctx := context.TODO()
+	var errs *packer.MultiError
+	for _, snapshotSlug := range snapshotSlugs {
+		log.Printf("Destroying snapshot: %s", snapshotSlug)
+		if err := a.apiClient.DeleteSnapshot(ctx, snapshotSlug); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("failed to delete snapshot %s: %s", snapshotSlug, err))
+		}
+	}
+	if instanceID != "" {
+		log.Printf("Destroying instance: %s", instanceID)
+		if err := a.apiClient.DeleteInstance(ctx, instanceID); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("failed to delete instance %s: %s", instanceID, err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
 	return nil
 }
 
+// Id returns the snapshot as "location:snapshot_slug", one per location
+// separated by commas, or the instance identifier when no snapshot was
+// created.
 func (a *Artifact) Id() string {
-	if id, ok := a.StateData["instance_idetifier"].(string); ok {
+	if a.stateString("snapshot_slug") != "" {
+		slugs := a.snapshotSlugs()
+		var ids []string
+		for _, loc := range a.locations() {
+			ids = append(ids, fmt.Sprintf("%s:%s", loc, slugs[loc]))
+		}
+		return strings.Join(ids, ",")
+	}
+	if id := a.stateString("instance_identifier"); id != "" {
 		return id
 	}
 	return "unknown"
 }
 
+// stateString returns the string stored in StateData under name, or "".
+func (a *Artifact) stateString(name string) string {
+	s, _ := a.StateData[name].(string)
+	return s
+}
+
 // BuilderId returns the builder ID.
 func (a *Artifact) BuilderId() string {
 	return BuilderId
@@ -75,14 +230,23 @@ func (a *Artifact) PrintOnUI(ui packer.Ui) error {
 	}
 
 	// Display the artifact details without the generated password.
-	ui.Say(fmt.Sprintf("Build Artifact:\nInstance Identifier: %s\nInstance IP: %s",
+	details := fmt.Sprintf("Build Artifact:\nInstance Identifier: %s\nInstance IP: %s",
 		instanceIdentifier,
 		instanceIP,
-	))
-
-	// Optionally, inform the user that a password was generated.
-	if _, pwdOk := a.StateData["generated_password"].(string); pwdOk {
-		ui.Say("A secure password has been generated for the instance.")
+	)
+	if slug := a.stateString("snapshot_slug"); slug != "" {
+		details += fmt.Sprintf("\nSnapshot Name: %s\nSnapshot Slug: %s", a.stateString("snapshot_name"), slug)
+	}
+	details += fmt.Sprintf("\nImage: %s\nPlan: %s\nLocation: %s",
+		a.stateString("source_image"),
+		a.stateString("plan_slug"),
+		strings.Join(a.locations(), ", "),
+	)
+	ui.Say(details)
+
+	// Tell the user where the generated password can be found, if anywhere.
+	if path := a.stateString("password_output_file"); path != "" {
+		ui.Say(fmt.Sprintf("The root password of the instance was written to %s.", path))
 	}
 
 	return nil
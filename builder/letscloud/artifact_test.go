@@ -0,0 +1,197 @@
+package letscloud
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/testutil"
+)
+
+func TestArtifact_ImplementsArtifact(t *testing.T) {
+	var _ packer.Artifact = new(Artifact)
+}
+
+// testArtifactState includes a password so the tests can check it never
+// leaks out of the artifact, should one end up in the state again.
+func testArtifactState() map[string]interface{} {
+	return map[string]interface{}{
+		"instance_identifier":  "inst-2",
+		"instance_ip":          "192.0.2.2",
+		"generated_password":   "secret",
+		"password_output_file": "/tmp/root-password",
+		"snapshot_slug":        "snap-3",
+		"snapshot_name":        "golden",
+		"source_image":         "ubuntu-24.04-x86_64",
+		"plan_slug":            "1vcpu-1gb-10ssd",
+		"location_slug":        "mia1",
+	}
+}
+
+func TestArtifact_Id(t *testing.T) {
+	state := testArtifactState()
+	if got := NewArtifact(state).Id(); got != "mia1:snap-3" {
+		t.Errorf("expected ID %q, got %q", "mia1:snap-3", got)
+	}
+
+	delete(state, "snapshot_slug")
+	if got := NewArtifact(state).Id(); got != "inst-2" {
+		t.Errorf("expected the instance identifier without a snapshot, got %q", got)
+	}
+
+	if got := NewArtifact(map[string]interface{}{}).Id(); got != "unknown" {
+		t.Errorf("expected %q, got %q", "unknown", got)
+	}
+}
+
+func TestArtifact_IdLocations(t *testing.T) {
+	state := testArtifactState()
+	state["location_slugs"] = []string{"mia1", "nyc1"}
+	state["snapshot_slugs"] = map[string]string{"mia1": "snap-3", "nyc1": "snap-3"}
+
+	a := NewArtifact(state)
+	if got, want := a.Id(), "mia1:snap-3,nyc1:snap-3"; got != want {
+		t.Errorf("expected ID %q, got %q", want, got)
+	}
+	if s := a.String(); !strings.Contains(s, "locations 'mia1, nyc1'") {
+		t.Errorf("expected every location in %q", s)
+	}
+
+	images, ok := a.State(registryimage.ArtifactStateURI).([]*registryimage.Image)
+	if !ok || len(images) != 2 {
+		t.Fatalf("expected one registry image per location, got %#v", a.State(registryimage.ArtifactStateURI))
+	}
+	for i, loc := range []string{"mia1", "nyc1"} {
+		if images[i].ProviderRegion != loc || images[i].ImageID != "snap-3" {
+			t.Errorf("unexpected image for %s: %#v", loc, images[i])
+		}
+	}
+}
+
+func TestArtifact_String(t *testing.T) {
+	s := NewArtifact(testArtifactState()).String()
+	for _, want := range []string{"golden", "mia1:snap-3", "ubuntu-24.04-x86_64", "1vcpu-1gb-10ssd"} {
+		if !strings.Contains(s, want) {
+			t.Errorf("expected %q in %q", want, s)
+		}
+	}
+}
+
+func TestArtifact_PrintOnUI(t *testing.T) {
+	ui, out := testutil.Ui()
+	if err := NewArtifact(testArtifactState()).(*Artifact).PrintOnUI(ui); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	for _, want := range []string{"inst-2", "192.0.2.2", "golden", "snap-3", "ubuntu-24.04-x86_64", "1vcpu-1gb-10ssd", "mia1", "/tmp/root-password"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output\n%s", want, out)
+		}
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Errorf("expected the password not to be printed\n%s", out)
+	}
+}
+
+func TestArtifact_RegistryImage(t *testing.T) {
+	a := NewArtifact(testArtifactState())
+
+	img, ok := a.State(registryimage.ArtifactStateURI).(*registryimage.Image)
+	if !ok {
+		t.Fatalf("expected a registry image, got %#v", a.State(registryimage.ArtifactStateURI))
+	}
+	if err := img.Validate(); err != nil {
+		t.Errorf("unexpected validation error: %s", err)
+	}
+	want := registryimage.Image{
+		ImageID:        "snap-3",
+		ProviderName:   "letscloud",
+		ProviderRegion: "mia1",
+		SourceImageID:  "ubuntu-24.04-x86_64",
+	}
+	if img.ImageID != want.ImageID || img.ProviderName != want.ProviderName ||
+		img.ProviderRegion != want.ProviderRegion || img.SourceImageID != want.SourceImageID {
+		t.Errorf("expected %+v, got %+v", want, img)
+	}
+	if img.Labels["snapshot_name"] != "golden" || img.Labels["plan_slug"] != "1vcpu-1gb-10ssd" {
+		t.Errorf("expected snapshot name and plan labels, got %v", img.Labels)
+	}
+	for k, v := range img.Labels {
+		if v == "secret" {
+			t.Errorf("expected the password not to be a label, found it under %q", k)
+		}
+	}
+
+	state := testArtifactState()
+	delete(state, "snapshot_slug")
+	if img := NewArtifact(state).State(registryimage.ArtifactStateURI); img != nil {
+		t.Errorf("expected no registry image without a snapshot, got %#v", img)
+	}
+}
+
+func TestArtifact_Destroy(t *testing.T) {
+	cases := []struct {
+		name         string
+		keepInstance bool
+	}{
+		{"snapshot", false},
+		{"snapshot and kept instance", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+
+			b := testBuilder(t, server, map[string]interface{}{
+				"keep_instance": tc.keepInstance,
+			})
+			ui, out := testutil.Ui()
+
+			artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+			if err != nil {
+				t.Fatalf("unexpected error: %s\n%s", err, out)
+			}
+			if n := len(server.Snapshots()); n != 1 {
+				t.Fatalf("expected 1 snapshot, got %d", n)
+			}
+
+			if err := artifact.Destroy(); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if n := len(server.Snapshots()); n != 0 {
+				t.Errorf("expected the snapshot to be deleted, %d left", n)
+			}
+			if n := len(server.Instances()); n != 0 {
+				t.Errorf("expected no instance to be left, got %d", n)
+			}
+			if n := server.Calls("DELETE /instances/{id}"); n != 1 {
+				t.Errorf("expected the instance to be deleted once, got %d calls", n)
+			}
+		})
+	}
+}
+
+func TestArtifact_DestroyFailure(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.Fail("DELETE /snapshots/{slug}", fakeapi.Failure{Status: 404})
+
+	a := &Artifact{
+		StateData: testArtifactState(),
+		apiClient: testAPIClient(server, 0),
+	}
+	if err := a.Destroy(); err == nil || !strings.Contains(err.Error(), "snap-3") {
+		t.Errorf("expected an error naming the snapshot, got %v", err)
+	}
+	if n := server.Calls("DELETE /instances/{id}"); n != 0 {
+		t.Errorf("expected the instance not to be touched, got %d calls", n)
+	}
+
+	if err := NewArtifact(testArtifactState()).Destroy(); err == nil {
+		t.Error("expected an error without an API client")
+	}
+}
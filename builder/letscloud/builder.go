@@ -2,15 +2,15 @@ package letscloud
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"time"
 
 	"github.com/hashicorp/hcl/v2/hcldec"
 	"github.com/hashicorp/packer-plugin-sdk/communicator"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/multistep/commonsteps"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/letscloud-community/letscloud-go"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 )
 
 const BuilderId = "packer.letscloud"
@@ -28,35 +28,53 @@ func (b *Builder) Prepare(raws ...interface{}) ([]string, []string, error) {
 		return nil, nil, errs
 	}
 
-	return nil, nil, nil
+	// Data made available to provisioners and post-processors as
+	// ${build.<name>}.
+	generatedData := []string{
+		"InstanceID",
+		"InstanceIP",
+		"SSHKeySlug",
+		"SnapshotSlug",
+		"Location",
+		"Plan",
+		"SourceImage",
+	}
+	return generatedData, nil, nil
 }
 
 func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (packer.Artifact, error) {
 	ui.Say("Running LetsCloud builder...")
 
-	sdkClient, err := letscloud.New(b.config.APIKey)
-	if err != nil {
-		return nil, fmt.Errorf("unable to initialize LetsCloud client: %v", err)
-	}
-	if err := sdkClient.SetTimeout(60 * time.Second); err != nil {
-		ui.Error(fmt.Sprintf("Failed to set timeout: %v", err))
-	}
+	apiClient := b.config.apiClient(b.config.retry, b.config.APIRequestsPerSecond)
 
 	// Setup the state bag and initial state for the steps
 	state := new(multistep.BasicStateBag)
-	state.Put("sdkClient", sdkClient)
+	state.Put("apiClient", apiClient)
 	state.Put("config", &b.config)
 	state.Put("hook", hook)
 	state.Put("ui", ui)
 
+	generatedData := &packerbuilderdata.GeneratedData{State: state}
+	generatedData.Put("Location", b.config.LocationSlug)
+	generatedData.Put("Plan", b.config.PlanSlug)
+	generatedData.Put("SourceImage", b.config.SourceImage())
+
 	steps := []multistep.Step{
 		&StepCreateSSHKey{
-			sdkClient: sdkClient,
-			config:    &b.config,
+			apiClient:     apiClient,
+			config:        &b.config,
+			generatedData: generatedData,
 		},
+		multistep.If(b.config.PackerDebug && b.config.Comm.SSHPrivateKeyFile == "" && b.config.SSHSlug == "",
+			&communicator.StepDumpSSHKey{
+				Path: fmt.Sprintf("letscloud_%s.pem", b.config.PackerBuildName),
+				SSH:  &b.config.Comm.SSH,
+			},
+		),
 		&StepCreateInstance{
-			sdkClient: sdkClient,
-			config:    &b.config,
+			apiClient:     apiClient,
+			config:        &b.config,
+			generatedData: generatedData,
 		},
 		&communicator.StepConnect{
 			Config:    &b.config.Comm,
@@ -67,14 +85,22 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		&commonsteps.StepCleanupTempKeys{
 			Comm: &b.config.Comm,
 		},
-		&StepShutdown{
-			sdkClient: sdkClient,
-			config:    &b.config,
-		},
-		&StepSnapshot{
-			sdkClient: sdkClient,
+	}
+
+	// Validation builds only shut the instance down to exercise
+	// shutdown_command, and take no snapshot.
+	if !b.config.SkipCreateSnapshot || b.config.ShutdownCommand != "" {
+		steps = append(steps, &StepShutdown{
+			apiClient: apiClient,
 			config:    &b.config,
-		},
+		})
+	}
+	if !b.config.SkipCreateSnapshot {
+		steps = append(steps, &StepSnapshot{
+			apiClient:     apiClient,
+			config:        &b.config,
+			generatedData: generatedData,
+		})
 	}
 
 	// Run!
@@ -86,14 +112,30 @@ func (b *Builder) Run(ctx context.Context, ui packer.Ui, hook packer.Hook) (pack
 		return nil, err.(error)
 	}
 
+	// If we were interrupted or cancelled, then just exit.
+	if _, ok := state.GetOk(multistep.StateCancelled); ok {
+		return nil, errors.New("build was cancelled")
+	}
+	if _, ok := state.GetOk(multistep.StateHalted); ok {
+		return nil, errors.New("build was halted")
+	}
+
 	artifact := &Artifact{
 		// Add the builder generated data to the artifact StateData so that post-processors
 		// can access them.
 		StateData: map[string]interface{}{
-			"instance_identifier": state.Get("instance_identifier"),
-			"instance_ip":         state.Get("instance_ip"),
-			"generated_password":  state.Get("generated_password"),
+			"instance_identifier":  state.Get("instance_identifier"),
+			"instance_ip":          state.Get("instance_ip"),
+			"password_output_file": b.config.PasswordOutputFile,
+			"snapshot_slug":        state.Get("snapshot_slug"),
+			"snapshot_name":        state.Get("snapshot_name"),
+			"source_image":         b.config.SourceImage(),
+			"plan_slug":            b.config.PlanSlug,
+			"location_slug":        b.config.LocationSlug,
+			"generated_data":       state.Get("generated_data"),
 		},
+		apiClient:    apiClient,
+		keepInstance: b.config.KeepInstance,
 	}
 	return artifact, nil
 }
@@ -18,7 +18,7 @@ import (
 //go:embed test-fixtures/template.pkr.hcl
 var testBuilderHCL2Basic string
 
-// Run with: PACKER_ACC=1 go test -count 1 -v ./builder/letscloud/builder_acc_test.go  -timeout=120m
+// Run with: PACKER_ACC=1 LETSCLOUD_API_KEY=... go test -count 1 -v ./builder/letscloud/builder_acc_test.go  -timeout=120m
 func TestAccLetscloudBuilder(t *testing.T) {
 	testCase := &acctest.PluginTestCase{
 		Name: "letscloud_builder_basic_test",
@@ -29,7 +29,7 @@ func TestAccLetscloudBuilder(t *testing.T) {
 			return nil
 		},
 		Template: testBuilderHCL2Basic,
-		Type:     "letscloud-my-builder",
+		Type:     "letscloud",
 		Check: func(buildCommand *exec.Cmd, logfile string) error {
 			if buildCommand.ProcessState != nil {
 				if buildCommand.ProcessState.ExitCode() != 0 {
@@ -49,7 +49,7 @@ func TestAccLetscloudBuilder(t *testing.T) {
 			}
 			logsString := string(logsBytes)
 
-			buildGeneratedDataLog := "letscloud-my-builder.basic-example: build generated data: mock-build-data"
+			buildGeneratedDataLog := "letscloud.basic-example: build generated data: \\S+ mia1"
 			if matched, _ := regexp.MatchString(buildGeneratedDataLog+".*", logsString); !matched {
 				t.Fatalf("logs doesn't contain expected foo value %q", logsString)
 			}
@@ -0,0 +1,611 @@
+package letscloud
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/testutil"
+)
+
+// testRequestsPerSecond keeps the shared rate limiter of the fake API key out
+// of the way of the tests.
+const testRequestsPerSecond = 10000
+
+func init() {
+	pollInterval = 5 * time.Millisecond
+	retryBaseDelay = time.Millisecond
+}
+
+func testConfig() map[string]interface{} {
+	return map[string]interface{}{
+		"api_key":                 fakeapi.DefaultAPIKey,
+		"api_requests_per_second": testRequestsPerSecond,
+		"location_slug":           "mia1",
+		"plan_slug":               "1vcpu-1gb-10ssd",
+		"image_slug":              "ubuntu-24.04-x86_64",
+		"communicator":            "none",
+	}
+}
+
+// testBuilder prepares a Builder talking to server with the given
+// configuration overrides applied on top of testConfig.
+func testBuilder(t *testing.T, server *fakeapi.Server, overrides map[string]interface{}) *Builder {
+	t.Helper()
+
+	raw := testConfig()
+	raw["api_url"] = server.URL
+	for k, v := range overrides {
+		raw[k] = v
+	}
+
+	b := new(Builder)
+	if _, _, err := b.Prepare(raw); err != nil {
+		t.Fatalf("unexpected prepare error: %s", err)
+	}
+	return b
+}
+
+func TestBuilder_ImplementsBuilder(t *testing.T) {
+	var _ packer.Builder = new(Builder)
+}
+
+func TestBuilderRun(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+
+	b := testBuilder(t, server, map[string]interface{}{
+		"snapshot_name": "golden",
+	})
+	ui, out := testutil.Ui()
+
+	artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	if artifact == nil {
+		t.Fatal("expected an artifact")
+	}
+
+	snapshots := server.Snapshots()
+	if len(snapshots) != 1 {
+		t.Fatalf("expected 1 snapshot, got %d", len(snapshots))
+	}
+	if want := "mia1:" + snapshots[0].Slug; artifact.Id() != want {
+		t.Errorf("expected artifact ID %q, got %q", want, artifact.Id())
+	}
+	if got := artifact.State("snapshot_name"); got != "golden" {
+		t.Errorf("expected snapshot_name %q in the artifact, got %v", "golden", got)
+	}
+	if snapshots[0].Label != "golden" {
+		t.Errorf("expected snapshot label %q, got %q", "golden", snapshots[0].Label)
+	}
+	if n := len(server.Instances()); n != 0 {
+		t.Errorf("expected instance to be deleted, %d left", n)
+	}
+	if n := len(server.SSHKeys()); n != 0 {
+		t.Errorf("expected temporary ssh key to be deleted, %d left", n)
+	}
+	if n := server.Calls("PUT /instances/{id}/power-off"); n != 1 {
+		t.Errorf("expected 1 power-off call, got %d", n)
+	}
+	if n := server.Calls("GET /instances"); n != 0 {
+		t.Errorf("expected the instance to be polled by identifier, got %d list calls", n)
+	}
+}
+
+func TestBuilderRun_password(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "password")
+	b := testBuilder(t, server, map[string]interface{}{
+		"keep_instance":        true,
+		"password_output_file": path,
+	})
+	ui, out := testutil.Ui()
+
+	artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+
+	instances := server.Instances()
+	if len(instances) != 1 {
+		t.Fatalf("expected the kept instance, got %d instances", len(instances))
+	}
+	password := instances[0].RootPassword
+
+	if strings.Contains(out.String(), password) {
+		t.Errorf("expected the password not to be printed\n%s", out)
+	}
+	serialized, err := artifact.(*Artifact).Serialize()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(string(serialized), password) {
+		t.Errorf("expected the password not to be in the artifact: %s", serialized)
+	}
+
+	written, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the password file to be written: %s", err)
+	}
+	if strings.TrimSpace(string(written)) != password {
+		t.Errorf("expected the password file to hold the root password, got %q", written)
+	}
+	if fi, err := os.Stat(path); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("expected the password file to be private, got %v (%v)", fi.Mode(), err)
+	}
+}
+
+func TestBuilderRun_generatedData(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+
+	b := new(Builder)
+	raw := testConfig()
+	raw["api_url"] = server.URL
+	names, _, err := b.Prepare(raw)
+	if err != nil {
+		t.Fatalf("unexpected prepare error: %s", err)
+	}
+
+	ui, out := testutil.Ui()
+	artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+
+	want := map[string]interface{}{
+		"InstanceID":   "inst-2",
+		"InstanceIP":   "192.0.2.2",
+		"SSHKeySlug":   "key-1",
+		"SnapshotSlug": "snap-3",
+		"Location":     "mia1",
+		"Plan":         "1vcpu-1gb-10ssd",
+		"SourceImage":  "ubuntu-24.04-x86_64",
+	}
+	if len(names) != len(want) {
+		t.Errorf("expected %d generated data names, got %v", len(want), names)
+	}
+	data, ok := artifact.State("generated_data").(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected generated data in the artifact, got %#v", artifact.State("generated_data"))
+	}
+	for _, name := range names {
+		if data[name] != want[name] {
+			t.Errorf("expected %s = %v, got %v", name, want[name], data[name])
+		}
+	}
+}
+
+func TestBuilderRun_keepInstance(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+
+	b := testBuilder(t, server, map[string]interface{}{
+		"keep_instance": true,
+	})
+	ui, out := testutil.Ui()
+
+	if _, err := b.Run(context.Background(), ui, &packer.MockHook{}); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	if n := len(server.Instances()); n != 1 {
+		t.Errorf("expected instance to be kept, got %d", n)
+	}
+}
+
+func TestBuilderRun_skipCreateSnapshot(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+
+	b := testBuilder(t, server, map[string]interface{}{
+		"skip_create_snapshot": true,
+	})
+	ui, out := testutil.Ui()
+
+	artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	if artifact == nil {
+		t.Fatal("expected an artifact")
+	}
+	if id := artifact.State("instance_identifier"); id == nil || artifact.Id() != id {
+		t.Errorf("expected the artifact to be identified by the instance, got %q", artifact.Id())
+	}
+	if n := len(server.Snapshots()); n != 0 {
+		t.Errorf("expected no snapshot, got %d", n)
+	}
+	if n := server.Calls("PUT /instances/{id}/power-off"); n != 0 {
+		t.Errorf("expected the instance not to be powered off, got %d calls", n)
+	}
+	if n := len(server.Instances()); n != 0 {
+		t.Errorf("expected the instance to be deleted, %d left", n)
+	}
+}
+
+func TestBuilderRun_instanceWithoutIdentifier(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.HideCreatedInstance = true
+
+	b := testBuilder(t, server, nil)
+	ui, out := testutil.Ui()
+
+	artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	if got := artifact.State("instance_identifier"); got != "inst-2" {
+		t.Errorf("expected instance inst-2 to be found by label, got %v", got)
+	}
+	if n := server.Calls("GET /instances"); n == 0 {
+		t.Error("expected the instance to be looked up by listing instances")
+	}
+	if n := len(server.Instances()); n != 0 {
+		t.Errorf("expected the instance to be deleted, %d left", n)
+	}
+}
+
+func TestBuilderRun_instanceWithoutAddress(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.AddressPolls = 2
+
+	b := testBuilder(t, server, nil)
+	ui, out := testutil.Ui()
+
+	artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	if got := artifact.State("instance_ip"); got != "192.0.2.2" {
+		t.Errorf("expected the instance IP once reported, got %v", got)
+	}
+	if !strings.Contains(out.String(), "has no IP address yet") {
+		t.Errorf("expected the wait for an IP address to be reported\n%s", out)
+	}
+}
+
+func TestBuilderRun_instanceWithoutIdentifierListFails(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.HideCreatedInstance = true
+	server.Fail("GET /instances", fakeapi.Failure{})
+
+	b := testBuilder(t, server, nil)
+	ui, out := testutil.Ui()
+
+	if _, err := b.Run(context.Background(), ui, &packer.MockHook{}); err == nil {
+		t.Fatalf("expected an error\n%s", out)
+	}
+	// The instance exists but was never identified, so it cannot be
+	// cleaned up.
+	if n := len(server.Instances()); n != 1 {
+		t.Errorf("expected the unidentified instance to be left, got %d", n)
+	}
+}
+
+func TestBuilderRun_createInstanceFailedAfterCreating(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.Fail("POST /instances", fakeapi.Failure{Times: 1, Processed: true})
+
+	b := testBuilder(t, server, nil)
+	ui, out := testutil.Ui()
+
+	artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+	if err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	if got := artifact.State("instance_identifier"); got != "inst-2" {
+		t.Errorf("expected instance inst-2 to be found by label, got %v", got)
+	}
+	if n := server.Calls("POST /instances"); n != 1 {
+		t.Errorf("expected the instance not to be created again, got %d requests", n)
+	}
+	if n := len(server.Instances()); n != 0 {
+		t.Errorf("expected the instance to be deleted, %d left", n)
+	}
+}
+
+func TestBuilderRun_sshSlug(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+
+	key, err := testAPIClient(server, 0).CreateSSHKey(context.Background(), "existing", "ssh-ed25519 AAAAexisting")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	b := testBuilder(t, server, map[string]interface{}{
+		"ssh_slug": key.Slug,
+	})
+	ui, out := testutil.Ui()
+
+	if _, err := b.Run(context.Background(), ui, &packer.MockHook{}); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+	if n := len(server.SSHKeys()); n != 1 {
+		t.Errorf("expected the provided SSH key to be kept, got %d keys", n)
+	}
+}
+
+func TestBuilderRun_sourceSnapshot(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	source := server.AddSnapshot(domains.Snapshot{Label: "base-1718000000", Locations: []string{"mia1"}})
+
+	b := testBuilder(t, server, map[string]interface{}{
+		"image_slug":           "",
+		"source_snapshot_slug": source,
+	})
+	ui, out := testutil.Ui()
+
+	if _, err := b.Run(context.Background(), ui, &packer.MockHook{}); err != nil {
+		t.Fatalf("unexpected error: %s\n%s", err, out)
+	}
+
+	for _, snap := range server.Snapshots() {
+		if snap.Slug != source && snap.OsReference != source {
+			t.Errorf("expected new snapshot to be built from %q, got %q", source, snap.OsReference)
+		}
+	}
+}
+
+func TestBuilderRun_sourceSnapshotWrongLocation(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	source := server.AddSnapshot(domains.Snapshot{Label: "base", Locations: []string{"gru1"}})
+
+	b := testBuilder(t, server, map[string]interface{}{
+		"image_slug":           "",
+		"source_snapshot_slug": source,
+	})
+	ui, out := testutil.Ui()
+
+	if _, err := b.Run(context.Background(), ui, &packer.MockHook{}); err == nil {
+		t.Fatalf("expected an error\n%s", out)
+	}
+	if n := server.Calls("POST /instances"); n != 0 {
+		t.Errorf("expected no instance to be created, got %d calls", n)
+	}
+}
+
+func TestBuilderRun_timeouts(t *testing.T) {
+	cases := []struct {
+		option string
+		polls  func(*fakeapi.Server) *int
+		want   string
+	}{
+		{"instance_create_timeout", func(s *fakeapi.Server) *int { return &s.InstancePolls }, "waiting for instance to be built"},
+		{"instance_create_timeout", func(s *fakeapi.Server) *int { return &s.AddressPolls }, "waiting for instance to get an IP address"},
+		{"shutdown_timeout", func(s *fakeapi.Server) *int { return &s.PowerOffPolls }, "did not power off"},
+		{"snapshot_timeout", func(s *fakeapi.Server) *int { return &s.SnapshotPolls }, "did not finish building"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.option, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+			*tc.polls(server) = 1000
+
+			b := testBuilder(t, server, map[string]interface{}{
+				tc.option: "50ms",
+			})
+			ui, out := testutil.Ui()
+
+			_, err := b.Run(context.Background(), ui, &packer.MockHook{})
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("expected error containing %q, got %v\n%s", tc.want, err, out)
+			}
+			if !strings.Contains(out.String(), "after") && !strings.Contains(out.String(), "waited") {
+				t.Errorf("expected elapsed time on the UI\n%s", out)
+			}
+		})
+	}
+}
+
+func TestBuilderRun_cancelled(t *testing.T) {
+	cases := []struct {
+		name  string
+		polls func(*fakeapi.Server) *int
+	}{
+		{"waiting for instance", func(s *fakeapi.Server) *int { return &s.InstancePolls }},
+		{"waiting for power off", func(s *fakeapi.Server) *int { return &s.PowerOffPolls }},
+		{"waiting for snapshot", func(s *fakeapi.Server) *int { return &s.SnapshotPolls }},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+			*tc.polls(server) = 1000000
+
+			b := testBuilder(t, server, nil)
+			ui, out := testutil.Ui()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			artifact, err := b.Run(ctx, ui, &packer.MockHook{})
+			if err == nil {
+				t.Fatalf("expected an error\n%s", out)
+			}
+			if artifact != nil {
+				t.Errorf("expected no artifact, got %v", artifact)
+			}
+			if d := time.Since(start); d > 5*time.Second {
+				t.Errorf("expected Run to return promptly, took %s", d)
+			}
+
+			if n := len(server.Instances()); n != 0 {
+				t.Errorf("expected the instance to be deleted, %d left\n%s", n, out)
+			}
+			if n := len(server.SSHKeys()); n != 0 {
+				t.Errorf("expected the ssh key to be deleted, %d left\n%s", n, out)
+			}
+		})
+	}
+}
+
+func TestBuilderRun_failures(t *testing.T) {
+	cases := []struct {
+		name    string
+		route   string
+		failure fakeapi.Failure
+		// configuration overrides
+		config map[string]interface{}
+		// whether the build is expected to succeed despite the failure
+		succeed bool
+		// resources expected to be left in the account afterwards
+		instances, sshKeys, snapshots int
+	}{
+		{
+			name:  "create ssh key",
+			route: "POST /sshkeys",
+		},
+		{
+			name:      "create ssh key is retried",
+			route:     "POST /sshkeys",
+			failure:   fakeapi.Failure{Times: 1},
+			succeed:   true,
+			snapshots: 1,
+		},
+		{
+			name:      "ssh key created despite the failure",
+			route:     "POST /sshkeys",
+			failure:   fakeapi.Failure{Times: 1, Processed: true},
+			succeed:   true,
+			snapshots: 1,
+		},
+		{
+			name:  "create instance",
+			route: "POST /instances",
+		},
+		{
+			name:      "create instance is retried",
+			route:     "POST /instances",
+			failure:   fakeapi.Failure{Times: 1},
+			succeed:   true,
+			snapshots: 1,
+		},
+		{
+			name:  "get instance",
+			route: "GET /instances/{id}",
+		},
+		{
+			name:  "power off",
+			route: "PUT /instances/{id}/power-off",
+		},
+		{
+			name:  "create snapshot",
+			route: "POST /instances/{id}/snapshots",
+		},
+		{
+			name:      "create snapshot is retried",
+			route:     "POST /instances/{id}/snapshots",
+			failure:   fakeapi.Failure{Times: 1},
+			succeed:   true,
+			snapshots: 1,
+		},
+		{
+			name:      "snapshot created despite the failure",
+			route:     "POST /instances/{id}/snapshots",
+			failure:   fakeapi.Failure{Times: 1, Processed: true},
+			succeed:   true,
+			snapshots: 1,
+		},
+		{
+			name:      "snapshot status is retried",
+			route:     "GET /snapshots/{slug}",
+			failure:   fakeapi.Failure{Times: 1},
+			succeed:   true,
+			snapshots: 1,
+		},
+		{
+			name:      "snapshot not found",
+			route:     "GET /snapshots/{slug}",
+			failure:   fakeapi.Failure{Status: http.StatusNotFound},
+			snapshots: 1,
+		},
+		{
+			name:      "delete instance",
+			route:     "DELETE /instances/{id}",
+			succeed:   true,
+			instances: 1,
+			snapshots: 1,
+		},
+		{
+			name:      "delete ssh key",
+			route:     "DELETE /sshkeys",
+			succeed:   true,
+			sshKeys:   1,
+			snapshots: 1,
+		},
+		{
+			name:    "unauthorized",
+			route:   "POST /sshkeys",
+			failure: fakeapi.Failure{Status: http.StatusUnauthorized},
+		},
+		{
+			name:   "password file not writable",
+			config: map[string]interface{}{"password_output_file": "/nonexistent/dir/password"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+			if tc.route != "" {
+				server.Fail(tc.route, tc.failure)
+			}
+
+			b := testBuilder(t, server, tc.config)
+			ui, out := testutil.Ui()
+
+			artifact, err := b.Run(context.Background(), ui, &packer.MockHook{})
+			if tc.succeed {
+				if err != nil {
+					t.Fatalf("unexpected error: %s\n%s", err, out)
+				}
+				if artifact == nil {
+					t.Fatal("expected an artifact")
+				}
+			} else {
+				if err == nil {
+					t.Fatalf("expected an error\n%s", out)
+				}
+				if artifact != nil {
+					t.Errorf("expected no artifact, got %v", artifact)
+				}
+				if !strings.Contains(out.String(), "Failed") && !strings.Contains(out.String(), "Error") {
+					t.Errorf("expected failure to be reported on the UI\n%s", out)
+				}
+			}
+
+			if n := len(server.Instances()); n != tc.instances {
+				t.Errorf("expected %d instances left, got %d", tc.instances, n)
+			}
+			if n := len(server.SSHKeys()); n != tc.sshKeys {
+				t.Errorf("expected %d ssh keys left, got %d", tc.sshKeys, n)
+			}
+			if n := len(server.Snapshots()); n != tc.snapshots {
+				t.Errorf("expected %d snapshots, got %d", tc.snapshots, n)
+			}
+		})
+	}
+}
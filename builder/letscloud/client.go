@@ -0,0 +1,415 @@
+package letscloud
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/letscloud-community/letscloud-go/domains"
+)
+
+// defaultAPIURL is the endpoint used by the SDK when `api_url` is not set.
+const defaultAPIURL = "https://core.letscloud.io/api"
+
+// Defaults of the retry policy applied to API calls.
+const (
+	defaultAPIMaxRetries      = 5
+	defaultAPIRetryMaxBackoff = 30 * time.Second
+)
+
+// retryBaseDelay is the delay before the first retry; it doubles on each
+// following attempt.
+var retryBaseDelay = time.Second
+
+// APIClient calls the LetsCloud endpoints used by the builder and
+// post-processors directly, as the SDK neither returns everything they need
+// from the responses nor exposes the status codes needed to tell transient
+// failures apart.
+type APIClient struct {
+	baseURL    string
+	apiKey     string
+	httpClient *http.Client
+	retry      retryPolicy
+	limiter    *rateLimiter
+}
+
+// retryPolicy controls how failed requests are retried.
+type retryPolicy struct {
+	maxRetries int
+	maxBackoff time.Duration
+}
+
+// apiError is an unsuccessful answer from the LetsCloud API.
+type apiError struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *apiError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("LetsCloud API returned HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("%s (HTTP %d)", e.Message, e.StatusCode)
+}
+
+// retryable reports whether a request sent with method may succeed if sent
+// again after failing with err. Requests that create something (POST) are
+// only resent when the API provably did not act on them: it rate limited
+// them, it was unavailable and said when to come back, or the connection was
+// refused. After any other failure the resource may exist already, and
+// sending the request again could create a duplicate.
+func retryable(method string, err error) bool {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		if method == http.MethodPost {
+			return apiErr.StatusCode == http.StatusTooManyRequests ||
+				(apiErr.StatusCode == http.StatusServiceUnavailable && apiErr.RetryAfter > 0)
+		}
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return method != http.MethodPost || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// mayHaveSucceeded reports whether a POST that failed with err may have been
+// acted on by the API anyway, so that what it creates may exist.
+func mayHaveSucceeded(err error) bool {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode >= 500 && !retryable(http.MethodPost, err)
+	}
+	return !retryable(http.MethodPost, err)
+}
+
+// apiClient returns a client for direct API calls with the same account and
+// endpoint as Client. Its requests share the rate limit of the API key with
+// every other client, in this plugin process and in the others.
+func (c *AccessConfig) apiClient(retry retryPolicy, requestsPerSecond float64) *APIClient {
+	baseURL := c.APIURL
+	if baseURL == "" {
+		baseURL = defaultAPIURL
+	}
+	return &APIClient{
+		baseURL:    baseURL,
+		apiKey:     c.APIKey,
+		httpClient: &http.Client{Timeout: defaultClientTimeout},
+		retry:      retry,
+		limiter:    rateLimiters.rateLimiter(c.APIKey, requestsPerSecond),
+	}
+}
+
+// NewAPIClient returns a client for direct API calls with the default retry
+// policy and rate limit.
+func (c *AccessConfig) NewAPIClient() *APIClient {
+	retry := retryPolicy{maxRetries: defaultAPIMaxRetries, maxBackoff: defaultAPIRetryMaxBackoff}
+	return c.apiClient(retry, defaultAPIRequestsPerSecond)
+}
+
+// withTimeout returns a copy of the client whose requests time out after d,
+// or never if d is zero.
+func (c *APIClient) withTimeout(d time.Duration) *APIClient {
+	client := *c
+	client.httpClient = &http.Client{Timeout: d, Transport: c.httpClient.Transport}
+	return &client
+}
+
+// requestBody makes the body of a request, and its content type. It is
+// called for every attempt, so retries can resend bodies that are streamed.
+type requestBody func() (body io.Reader, contentType string, err error)
+
+// do sends a request to path, retrying transient failures, and decodes the
+// data field of a successful response into out, if out is not nil.
+func (c *APIClient) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var newBody requestBody
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		newBody = func() (io.Reader, string, error) {
+			return bytes.NewReader(payload), "application/json", nil
+		}
+	}
+	return c.doBody(ctx, method, path, newBody, out)
+}
+
+// doBody is do for bodies that are not JSON.
+func (c *APIClient) doBody(ctx context.Context, method, path string, newBody requestBody, out interface{}) error {
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, newBody, out)
+		if err == nil {
+			return nil
+		}
+
+		if ctx.Err() != nil || !retryable(method, err) || attempt >= c.retry.maxRetries {
+			return err
+		}
+
+		delay := c.retry.backoff(attempt)
+		var apiErr *apiError
+		if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+			delay = min(apiErr.RetryAfter, c.retry.maxBackoff)
+		}
+		log.Printf("[WARN] %s %s failed: %s; retrying in %s (retry %d of %d)",
+			method, path, err, delay, attempt+1, c.retry.maxRetries)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// send makes a single request, once the rate limiter allows it.
+func (c *APIClient) send(ctx context.Context, method, path string, newBody requestBody, out interface{}) error {
+	if err := c.limiter.Wait(ctx); err != nil {
+		return err
+	}
+
+	var reqBody io.Reader
+	var contentType string
+	if newBody != nil {
+		var err error
+		if reqBody, contentType, err = newBody(); err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		if closer, ok := reqBody.(io.Closer); ok {
+			closer.Close()
+		}
+		return err
+	}
+	req.Header.Set("api-token", c.apiKey)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var envelope struct {
+		domains.CommonResponse
+		Data json.RawMessage `json:"data"`
+	}
+	// Error responses are not always JSON; the status code is enough then.
+	decodeErr := json.NewDecoder(resp.Body).Decode(&envelope)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 || (decodeErr == nil && !envelope.Success) {
+		return &apiError{
+			StatusCode: resp.StatusCode,
+			Message:    envelope.Message,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if decodeErr != nil {
+		return fmt.Errorf("unable to decode response from %s %s: %s", method, path, decodeErr)
+	}
+
+	if out == nil || len(envelope.Data) == 0 || string(envelope.Data) == "null" {
+		return nil
+	}
+	return json.Unmarshal(envelope.Data, out)
+}
+
+// backoff returns the delay before retry number attempt+1: exponential,
+// capped at maxBackoff, with jitter so parallel builds do not retry in step.
+func (p retryPolicy) backoff(attempt int) time.Duration {
+	d := p.maxBackoff
+	if attempt < 32 {
+		d = min(retryBaseDelay<<attempt, p.maxBackoff)
+	}
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter parses a Retry-After header, given either in seconds or as
+// an HTTP date. It returns zero when the header is missing or invalid.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(secs, 0)) * time.Second
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0)
+	}
+	return 0
+}
+
+// CreateSSHKey registers the public key, in authorized_keys format, as an SSH
+// key of the account.
+func (c *APIClient) CreateSSHKey(ctx context.Context, title, publicKey string) (*domains.SSHKey, error) {
+	var key domains.SSHKey
+	err := c.do(ctx, http.MethodPost, "/sshkeys", domains.SSHKeyCreateRequest{Title: title, Key: publicKey}, &key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// SSHKeys lists the SSH keys of the account.
+func (c *APIClient) SSHKeys(ctx context.Context) ([]domains.SSHKey, error) {
+	var keys []domains.SSHKey
+	if err := c.do(ctx, http.MethodGet, "/sshkeys", nil, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// DeleteSSHKey deletes the SSH key identified by slug.
+func (c *APIClient) DeleteSSHKey(ctx context.Context, slug string) error {
+	return c.do(ctx, http.MethodDelete, "/sshkeys", domains.SSHKeyDelRequest{Slug: slug}, nil)
+}
+
+// CreateInstance creates an instance and returns its identifier. The
+// identifier is empty if the API did not include the new instance in its
+// response.
+func (c *APIClient) CreateInstance(ctx context.Context, req *domains.CreateInstanceRequest) (string, error) {
+	var inst domains.Instance
+	if err := c.do(ctx, http.MethodPost, "/instances", req, &inst); err != nil {
+		return "", err
+	}
+	return inst.Identifier, nil
+}
+
+// Instances lists the instances of the account.
+func (c *APIClient) Instances(ctx context.Context) ([]domains.Instance, error) {
+	var instances []domains.Instance
+	if err := c.do(ctx, http.MethodGet, "/instances", nil, &instances); err != nil {
+		return nil, err
+	}
+	return instances, nil
+}
+
+// Instance fetches the instance with the given identifier.
+func (c *APIClient) Instance(ctx context.Context, identifier string) (*domains.Instance, error) {
+	var inst domains.Instance
+	if err := c.do(ctx, http.MethodGet, "/instances/"+identifier, nil, &inst); err != nil {
+		return nil, err
+	}
+	return &inst, nil
+}
+
+// DeleteInstance deletes the instance with the given identifier.
+func (c *APIClient) DeleteInstance(ctx context.Context, identifier string) error {
+	return c.do(ctx, http.MethodDelete, "/instances/"+identifier, nil, nil)
+}
+
+// PowerOffInstance powers off the instance with the given identifier.
+func (c *APIClient) PowerOffInstance(ctx context.Context, identifier string) error {
+	return c.do(ctx, http.MethodPut, "/instances/"+identifier+"/power-off", nil, nil)
+}
+
+// CreateSnapshot queues a snapshot of the instance with the given label.
+func (c *APIClient) CreateSnapshot(ctx context.Context, identifier, label string) (*domains.Snapshot, error) {
+	var snap domains.Snapshot
+	err := c.do(ctx, http.MethodPost, "/instances/"+identifier+"/snapshots", domains.SnapshotCreateRequest{Label: label}, &snap)
+	if err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// DeleteSnapshot deletes the snapshot with the given slug.
+func (c *APIClient) DeleteSnapshot(ctx context.Context, slug string) error {
+	return c.do(ctx, http.MethodDelete, "/snapshots/"+slug, nil, nil)
+}
+
+// CopySnapshot starts replicating the snapshot to another location. The copy
+// is done once the location is listed in the snapshot's Locations.
+//
+// Experimental: the endpoint is not in the SDK nor in the API reference, so
+// its path, body and behaviour are assumptions that may not hold.
+func (c *APIClient) CopySnapshot(ctx context.Context, slug, locationSlug string) error {
+	body := struct {
+		LocationSlug string `json:"location_slug"`
+	}{locationSlug}
+	return c.do(ctx, http.MethodPost, "/snapshots/"+slug+"/copy", body, nil)
+}
+
+// ImportSnapshot uploads the disk image at path, in the given format ("qcow2"
+// or "raw"), as a snapshot labelled label in the location. The snapshot is
+// usable once it is built. The upload is streamed from the file and is not
+// subject to the client timeout; ctx bounds it instead.
+//
+// Experimental: the endpoint is not in the SDK nor in the API reference, so
+// its path, form fields and behaviour are assumptions that may not hold.
+func (c *APIClient) ImportSnapshot(ctx context.Context, path, format, label, locationSlug string) (*domains.Snapshot, error) {
+	newBody := func() (io.Reader, string, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, "", err
+		}
+
+		pr, pw := io.Pipe()
+		mw := multipart.NewWriter(pw)
+		go func() {
+			defer f.Close()
+			pw.CloseWithError(writeImportForm(mw, f, format, label, locationSlug))
+		}()
+		return pr, mw.FormDataContentType(), nil
+	}
+
+	var snap domains.Snapshot
+	if err := c.withTimeout(0).doBody(ctx, http.MethodPost, "/snapshots/import", newBody, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
+
+// writeImportForm writes the multipart form of an image import to mw.
+func writeImportForm(mw *multipart.Writer, image *os.File, format, label, locationSlug string) error {
+	fields := [][2]string{{"label", label}, {"location_slug", locationSlug}, {"format", format}}
+	for _, field := range fields {
+		if err := mw.WriteField(field[0], field[1]); err != nil {
+			return err
+		}
+	}
+
+	part, err := mw.CreateFormFile("image", filepath.Base(image.Name()))
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(part, image); err != nil {
+		return err
+	}
+	return mw.Close()
+}
+
+// Snapshots lists the snapshots of the account.
+func (c *APIClient) Snapshots(ctx context.Context) ([]domains.Snapshot, error) {
+	var snapshots []domains.Snapshot
+	if err := c.do(ctx, http.MethodGet, "/snapshots", nil, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// Snapshot fetches the snapshot with the given slug.
+func (c *APIClient) Snapshot(ctx context.Context, slug string) (*domains.Snapshot, error) {
+	var snap domains.Snapshot
+	if err := c.do(ctx, http.MethodGet, "/snapshots/"+slug, nil, &snap); err != nil {
+		return nil, err
+	}
+	return &snap, nil
+}
@@ -0,0 +1,288 @@
+package letscloud
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+)
+
+func testAPIClient(server *fakeapi.Server, maxRetries int) *APIClient {
+	access := AccessConfig{APIURL: server.URL, APIKey: fakeapi.DefaultAPIKey}
+	return access.apiClient(retryPolicy{maxRetries: maxRetries, maxBackoff: time.Second}, testRequestsPerSecond)
+}
+
+func TestAPIClient_retry(t *testing.T) {
+	cases := []struct {
+		name    string
+		failure fakeapi.Failure
+		// number of requests expected to be sent
+		calls   int
+		wantErr bool
+	}{
+		{name: "server error recovers", failure: fakeapi.Failure{Status: http.StatusServiceUnavailable, Times: 2}, calls: 3},
+		{name: "rate limited recovers", failure: fakeapi.Failure{Status: http.StatusTooManyRequests, Times: 1}, calls: 2},
+		{name: "retries exhausted", failure: fakeapi.Failure{Status: http.StatusBadGateway}, calls: 4, wantErr: true},
+		{name: "client error is permanent", failure: fakeapi.Failure{Status: http.StatusUnprocessableEntity}, calls: 1, wantErr: true},
+		{name: "unauthorized is permanent", failure: fakeapi.Failure{Status: http.StatusUnauthorized}, calls: 1, wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+			server.Fail("GET /instances", tc.failure)
+
+			_, err := testAPIClient(server, 3).Instances(context.Background())
+			if tc.wantErr {
+				var apiErr *apiError
+				if !errors.As(err, &apiErr) || apiErr.StatusCode != tc.failure.Status {
+					t.Errorf("expected an HTTP %d error, got %v", tc.failure.Status, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if n := server.Calls("GET /instances"); n != tc.calls {
+				t.Errorf("expected %d requests, got %d", tc.calls, n)
+			}
+		})
+	}
+}
+
+func TestAPIClient_retryPost(t *testing.T) {
+	cases := []struct {
+		name    string
+		failure fakeapi.Failure
+		// number of requests expected to be sent
+		calls int
+	}{
+		{name: "rate limited", failure: fakeapi.Failure{Status: http.StatusTooManyRequests, Times: 1}, calls: 2},
+		{name: "unavailable with retry after", failure: fakeapi.Failure{Status: http.StatusServiceUnavailable, Times: 1, RetryAfter: "1"}, calls: 2},
+		{name: "unavailable", failure: fakeapi.Failure{Status: http.StatusServiceUnavailable, Times: 1}, calls: 1},
+		{name: "server error", failure: fakeapi.Failure{Status: http.StatusInternalServerError, Times: 1}, calls: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+			server.Fail("POST /sshkeys", tc.failure)
+
+			client := testAPIClient(server, 3)
+			client.retry.maxBackoff = time.Millisecond
+			_, err := client.CreateSSHKey(context.Background(), "key", "ssh-ed25519 AAAA")
+			if wantErr := tc.calls == 1; (err != nil) != wantErr {
+				t.Errorf("expected error: %t, got %v", wantErr, err)
+			}
+			if n := server.Calls("POST /sshkeys"); n != tc.calls {
+				t.Errorf("expected %d requests, got %d", tc.calls, n)
+			}
+		})
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	refused := &url.Error{Op: "Post", URL: "http://127.0.0.1", Err: &net.OpError{
+		Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED),
+	}}
+	reset := &url.Error{Op: "Post", URL: "http://127.0.0.1", Err: &net.OpError{
+		Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET),
+	}}
+
+	cases := []struct {
+		method string
+		err    error
+		want   bool
+	}{
+		{http.MethodGet, &apiError{StatusCode: http.StatusInternalServerError}, true},
+		{http.MethodDelete, &apiError{StatusCode: http.StatusBadGateway}, true},
+		{http.MethodGet, &apiError{StatusCode: http.StatusNotFound}, false},
+		{http.MethodGet, reset, true},
+		{http.MethodPost, &apiError{StatusCode: http.StatusTooManyRequests}, true},
+		{http.MethodPost, &apiError{StatusCode: http.StatusServiceUnavailable, RetryAfter: time.Second}, true},
+		{http.MethodPost, &apiError{StatusCode: http.StatusServiceUnavailable}, false},
+		{http.MethodPost, &apiError{StatusCode: http.StatusInternalServerError}, false},
+		{http.MethodPost, refused, true},
+		{http.MethodPost, reset, false},
+	}
+
+	for _, tc := range cases {
+		if got := retryable(tc.method, tc.err); got != tc.want {
+			t.Errorf("retryable(%s, %v) = %t, want %t", tc.method, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestAPIClient_retryAfter(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.Fail("GET /instances", fakeapi.Failure{Status: http.StatusTooManyRequests, Times: 1, RetryAfter: "1"})
+
+	start := time.Now()
+	if _, err := testAPIClient(server, 3).Instances(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if d := time.Since(start); d < time.Second {
+		t.Errorf("expected Retry-After to be honoured, retried after %s", d)
+	}
+}
+
+func TestAPIClient_retryCancelled(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.Fail("GET /instances", fakeapi.Failure{Status: http.StatusServiceUnavailable, RetryAfter: "60"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	client := testAPIClient(server, 3)
+	client.retry.maxBackoff = time.Minute
+
+	start := time.Now()
+	if _, err := client.Instances(ctx); err == nil {
+		t.Fatal("expected an error")
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("expected the retry to stop when cancelled, took %s", d)
+	}
+}
+
+func TestAPIClient_importRetry(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.SetAvailable("mia1", true)
+	server.Fail("POST /snapshots/import", fakeapi.Failure{Status: http.StatusTooManyRequests, Times: 1})
+
+	image := []byte("disk image")
+	path := filepath.Join(t.TempDir(), "disk.raw")
+	if err := os.WriteFile(path, image, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := testAPIClient(server, 3).ImportSnapshot(context.Background(), path, "raw", "imported", "mia1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if snap.Size != len(image) {
+		t.Errorf("expected the whole image to be sent again on retry, got %d bytes", snap.Size)
+	}
+	if n := server.Calls("POST /snapshots/import"); n != 2 {
+		t.Errorf("expected 2 requests, got %d", n)
+	}
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := retryPolicy{maxBackoff: 10 * retryBaseDelay}
+
+	for attempt, limit := range []time.Duration{1, 2, 4, 8, 10, 10} {
+		limit *= retryBaseDelay
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(attempt); d < limit/2 || d > limit {
+				t.Fatalf("attempt %d: expected a delay in [%s, %s], got %s", attempt, limit/2, limit, d)
+			}
+		}
+	}
+	if d := p.backoff(100); d > p.maxBackoff {
+		t.Errorf("expected the delay to be capped at %s, got %s", p.maxBackoff, d)
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	cases := map[string]time.Duration{
+		"":                              0,
+		"3":                             3 * time.Second,
+		"-1":                            0,
+		"soon":                          0,
+		"Mon, 02 Jan 2006 15:04:05 GMT": 0,
+	}
+	for value, want := range cases {
+		if got := parseRetryAfter(value); got != want {
+			t.Errorf("parseRetryAfter(%q): expected %s, got %s", value, want, got)
+		}
+	}
+
+	future := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+	if got := parseRetryAfter(future); got < 59*time.Minute || got > time.Hour {
+		t.Errorf("expected an HTTP date an hour away to give about 1h, got %s", got)
+	}
+}
+
+func TestRateLimiter_shared(t *testing.T) {
+	registry := newLimiterRegistry(t.TempDir())
+
+	a := registry.rateLimiter("shared-key", 10)
+	if b := registry.rateLimiter("shared-key", 20); b != a {
+		t.Error("expected clients with the same API key to share a limiter")
+	}
+	if a.interval != 100*time.Millisecond {
+		t.Errorf("expected the lowest rate to apply, got an interval of %s", a.interval)
+	}
+	registry.rateLimiter("shared-key", 2)
+	if a.interval != 500*time.Millisecond {
+		t.Errorf("expected a lower rate to replace the current one, got an interval of %s", a.interval)
+	}
+	if c := registry.rateLimiter("other-key", 10); c == a || c.path == a.path {
+		t.Error("expected API keys to have their own limiters")
+	}
+	if strings.Contains(a.path, "shared-key") {
+		t.Errorf("expected the API key not to appear in %s", a.path)
+	}
+}
+
+func TestRateLimiter_acrossProcesses(t *testing.T) {
+	// Each registry stands for the plugin process of a parallel build.
+	dir := t.TempDir()
+	limiters := []*rateLimiter{
+		newLimiterRegistry(dir).rateLimiter("shared-key", 20),
+		newLimiterRegistry(dir).rateLimiter("shared-key", 20),
+	}
+
+	// 20 requests per second with a burst of 20: the 30 requests cannot
+	// complete in less than half a second if the schedule is shared.
+	start := time.Now()
+	for i := 0; i < 15; i++ {
+		for _, l := range limiters {
+			if err := l.Wait(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+	}
+	if d := time.Since(start); d < 450*time.Millisecond {
+		t.Errorf("expected the processes to share the rate limit, 30 requests took %s", d)
+	}
+}
+
+func TestAPIClient_rateLimited(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.APIKey = "rate-limited-key"
+
+	access := AccessConfig{APIURL: server.URL, APIKey: server.APIKey}
+	clients := []*APIClient{
+		access.apiClient(retryPolicy{}, 20),
+		access.apiClient(retryPolicy{}, 20),
+	}
+
+	// 20 requests per second with a burst of 20: the 40 requests made by
+	// both clients together cannot complete in less than a second.
+	start := time.Now()
+	for i := 0; i < 20; i++ {
+		for _, client := range clients {
+			if _, err := client.Instances(context.Background()); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+		}
+	}
+	if d := time.Since(start); d < 900*time.Millisecond {
+		t.Errorf("expected requests to be rate limited, 40 requests took %s", d)
+	}
+}
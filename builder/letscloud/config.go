@@ -20,24 +20,46 @@ const (
 	defaultStateTimeout = 10 * time.Minute
 	defaultSSHUsername  = "root"
 	defaultCommunicator = "ssh"
+
+	// defaultTemporaryKeyPairType is the type of the SSH key generated for
+	// the build.
+	defaultTemporaryKeyPairType = "ed25519"
 )
 
 // Config represents the configuration for the LetsCloud builder.
 type Config struct {
 	common.PackerConfig `mapstructure:",squash"`
 	Comm                communicator.Config `mapstructure:",squash"`
+	AccessConfig        `mapstructure:",squash"`
 	ctx                 interpolate.Context
 
-	APIKey       string `mapstructure:"api_key"`
-	LocationSlug string `mapstructure:"location_slug"`
-	PlanSlug     string `mapstructure:"plan_slug"`
-	ImageSlug    string `mapstructure:"image_slug"`
-	SSHSlug      string `mapstructure:"ssh_slug"`
-	Hostname     string `mapstructure:"hostname"`
-	Label        string `mapstructure:"label"`
-	SnapshotName string `mapstructure:"snapshot_name"`
-	StateTimeout string `mapstructure:"state_timeout,omitempty"` // Optional: Defaults to 10m
-	KeepInstance bool   `mapstructure:"keep_instance"`           // Optional: Defaults to false
+	LocationSlug       string `mapstructure:"location_slug"`
+	PlanSlug           string `mapstructure:"plan_slug"`
+	ImageSlug          string `mapstructure:"image_slug"`
+	SourceSnapshotSlug string `mapstructure:"source_snapshot_slug"` // Optional: Mutually exclusive with image_slug
+	SSHSlug            string `mapstructure:"ssh_slug"`
+	Hostname           string `mapstructure:"hostname"`
+	Label              string `mapstructure:"label"`
+	SnapshotName       string `mapstructure:"snapshot_name"`
+	ShutdownCommand    string `mapstructure:"shutdown_command"`        // Optional: Defaults to powering off through the API
+	StateTimeout       string `mapstructure:"state_timeout,omitempty"` // Optional: Defaults to 10m
+	KeepInstance       bool   `mapstructure:"keep_instance"`           // Optional: Defaults to false
+	SkipCreateSnapshot bool   `mapstructure:"skip_create_snapshot"`    // Optional: Defaults to false
+	PasswordOutputFile string `mapstructure:"password_output_file"`    // Optional: Defaults to not saving the root password
+
+	InstanceCreateTimeout string `mapstructure:"instance_create_timeout"` // Optional: Defaults to state_timeout
+	ShutdownTimeout       string `mapstructure:"shutdown_timeout"`        // Optional: Defaults to state_timeout
+	SnapshotTimeout       string `mapstructure:"snapshot_timeout"`        // Optional: Defaults to state_timeout
+
+	APIMaxRetries      *int   `mapstructure:"api_max_retries"`       // Optional: Defaults to 5
+	APIRetryMaxBackoff string `mapstructure:"api_retry_max_backoff"` // Optional: Defaults to 30s
+
+	APIRequestsPerSecond float64 `mapstructure:"api_requests_per_second"` // Optional: Defaults to 5
+
+	instanceCreateTimeout time.Duration
+	shutdownTimeout       time.Duration
+	snapshotTimeout       time.Duration
+	retry                 retryPolicy
 }
 
 // Prepare decodes the configuration and validates required fields.
@@ -74,12 +96,14 @@ func (c *Config) Prepare(raws ...interface{}) error {
 	// Initialize a MultiError to collect all validation errors
 	var errs *packer.MultiError
 
+	if es := c.AccessConfig.Prepare(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
 	// Validate required fields
 	requiredFields := map[string]string{
-		"api_key":       c.APIKey,
 		"location_slug": c.LocationSlug,
 		"plan_slug":     c.PlanSlug,
-		"image_slug":    c.ImageSlug,
 	}
 
 	for field, value := range requiredFields {
@@ -88,13 +112,76 @@ func (c *Config) Prepare(raws ...interface{}) error {
 		}
 	}
 
+	switch {
+	case c.ImageSlug == "" && c.SourceSnapshotSlug == "":
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("one of `image_slug` or `source_snapshot_slug` is required"))
+	case c.ImageSlug != "" && c.SourceSnapshotSlug != "":
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("only one of `image_slug` or `source_snapshot_slug` can be set"))
+	}
+
 	// Validate StateTimeout format or set default
 	if c.StateTimeout == "" {
 		c.StateTimeout = defaultStateTimeout.String()
-	} else {
-		if _, err := time.ParseDuration(c.StateTimeout); err != nil {
-			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid format for `state_timeout`: %s", err))
+	}
+	stateTimeout, err := parseTimeout("state_timeout", c.StateTimeout)
+	if err != nil {
+		errs = packer.MultiErrorAppend(errs, err)
+		stateTimeout = defaultStateTimeout
+	}
+
+	// The per-operation timeouts fall back to state_timeout
+	timeouts := []struct {
+		name  string
+		value *string
+		out   *time.Duration
+	}{
+		{"instance_create_timeout", &c.InstanceCreateTimeout, &c.instanceCreateTimeout},
+		{"shutdown_timeout", &c.ShutdownTimeout, &c.shutdownTimeout},
+		{"snapshot_timeout", &c.SnapshotTimeout, &c.snapshotTimeout},
+	}
+	for _, t := range timeouts {
+		if *t.value == "" {
+			*t.value = stateTimeout.String()
+		}
+		d, err := parseTimeout(t.name, *t.value)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, err)
+			continue
 		}
+		*t.out = d
+	}
+
+	// Retry policy for API calls
+	c.retry.maxRetries = defaultAPIMaxRetries
+	if c.APIMaxRetries != nil {
+		c.retry.maxRetries = *c.APIMaxRetries
+		if c.retry.maxRetries < 0 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("`api_max_retries` must not be negative"))
+		}
+	}
+	if c.APIRetryMaxBackoff == "" {
+		c.APIRetryMaxBackoff = defaultAPIRetryMaxBackoff.String()
+	}
+	c.retry.maxBackoff, err = parseTimeout("api_retry_max_backoff", c.APIRetryMaxBackoff)
+	if err != nil {
+		errs = packer.MultiErrorAppend(errs, err)
+	}
+
+	switch {
+	case c.APIRequestsPerSecond == 0:
+		c.APIRequestsPerSecond = defaultAPIRequestsPerSecond
+	case c.APIRequestsPerSecond < 0:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`api_requests_per_second` must be positive"))
+	}
+
+	// Temporary SSH keys are generated locally, ed25519 unless rsa is asked
+	// for.
+	switch c.Comm.SSHTemporaryKeyPairType {
+	case "":
+		c.Comm.SSHTemporaryKeyPairType = defaultTemporaryKeyPairType
+	case "ed25519", "rsa":
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`temporary_key_pair_type` must be ed25519 or rsa, got %q", c.Comm.SSHTemporaryKeyPairType))
 	}
 
 	// Prepare the communicator
@@ -109,12 +196,31 @@ func (c *Config) Prepare(raws ...interface{}) error {
 		return errs
 	}
 
-	// Protect the APIKey from being logged
-	packer.LogSecretFilter.Set(c.APIKey)
-
 	return nil
 }
 
+// parseTimeout parses the duration set for the option name, which must be
+// positive.
+func parseTimeout(name, value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid format for `%s`: %s", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("`%s` must be positive, got %s", name, value)
+	}
+	return d, nil
+}
+
+// SourceImage returns the slug the instance is launched from: either the
+// vendor image or the source snapshot.
+func (c *Config) SourceImage() string {
+	if c.SourceSnapshotSlug != "" {
+		return c.SourceSnapshotSlug
+	}
+	return c.ImageSlug
+}
+
 // ConfigSpec returns the HCL object spec for the configuration.
 func (c *Config) ConfigSpec() hcldec.ObjectSpec {
 	return c.FlatMapstructure().HCL2Spec()
@@ -68,15 +68,28 @@ type FlatConfig struct {
 	WinRMInsecure             *bool             `mapstructure:"winrm_insecure" cty:"winrm_insecure" hcl:"winrm_insecure"`
 	WinRMUseNTLM              *bool             `mapstructure:"winrm_use_ntlm" cty:"winrm_use_ntlm" hcl:"winrm_use_ntlm"`
 	APIKey                    *string           `mapstructure:"api_key" cty:"api_key" hcl:"api_key"`
+	APIURL                    *string           `mapstructure:"api_url" cty:"api_url" hcl:"api_url"`
+	Profile                   *string           `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile           *string           `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
 	LocationSlug              *string           `mapstructure:"location_slug" cty:"location_slug" hcl:"location_slug"`
 	PlanSlug                  *string           `mapstructure:"plan_slug" cty:"plan_slug" hcl:"plan_slug"`
 	ImageSlug                 *string           `mapstructure:"image_slug" cty:"image_slug" hcl:"image_slug"`
+	SourceSnapshotSlug        *string           `mapstructure:"source_snapshot_slug" cty:"source_snapshot_slug" hcl:"source_snapshot_slug"`
 	SSHSlug                   *string           `mapstructure:"ssh_slug" cty:"ssh_slug" hcl:"ssh_slug"`
 	Hostname                  *string           `mapstructure:"hostname" cty:"hostname" hcl:"hostname"`
 	Label                     *string           `mapstructure:"label" cty:"label" hcl:"label"`
 	SnapshotName              *string           `mapstructure:"snapshot_name" cty:"snapshot_name" hcl:"snapshot_name"`
+	ShutdownCommand           *string           `mapstructure:"shutdown_command" cty:"shutdown_command" hcl:"shutdown_command"`
 	StateTimeout              *string           `mapstructure:"state_timeout,omitempty" cty:"state_timeout" hcl:"state_timeout"`
 	KeepInstance              *bool             `mapstructure:"keep_instance" cty:"keep_instance" hcl:"keep_instance"`
+	SkipCreateSnapshot        *bool             `mapstructure:"skip_create_snapshot" cty:"skip_create_snapshot" hcl:"skip_create_snapshot"`
+	PasswordOutputFile        *string           `mapstructure:"password_output_file" cty:"password_output_file" hcl:"password_output_file"`
+	InstanceCreateTimeout     *string           `mapstructure:"instance_create_timeout" cty:"instance_create_timeout" hcl:"instance_create_timeout"`
+	ShutdownTimeout           *string           `mapstructure:"shutdown_timeout" cty:"shutdown_timeout" hcl:"shutdown_timeout"`
+	SnapshotTimeout           *string           `mapstructure:"snapshot_timeout" cty:"snapshot_timeout" hcl:"snapshot_timeout"`
+	APIMaxRetries             *int              `mapstructure:"api_max_retries" cty:"api_max_retries" hcl:"api_max_retries"`
+	APIRetryMaxBackoff        *string           `mapstructure:"api_retry_max_backoff" cty:"api_retry_max_backoff" hcl:"api_retry_max_backoff"`
+	APIRequestsPerSecond      *float64          `mapstructure:"api_requests_per_second" cty:"api_requests_per_second" hcl:"api_requests_per_second"`
 }
 
 // FlatMapstructure returns a new FlatConfig.
@@ -149,15 +162,28 @@ func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
 		"winrm_insecure":               &hcldec.AttrSpec{Name: "winrm_insecure", Type: cty.Bool, Required: false},
 		"winrm_use_ntlm":               &hcldec.AttrSpec{Name: "winrm_use_ntlm", Type: cty.Bool, Required: false},
 		"api_key":                      &hcldec.AttrSpec{Name: "api_key", Type: cty.String, Required: false},
+		"api_url":                      &hcldec.AttrSpec{Name: "api_url", Type: cty.String, Required: false},
+		"profile":                      &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file":             &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
 		"location_slug":                &hcldec.AttrSpec{Name: "location_slug", Type: cty.String, Required: false},
 		"plan_slug":                    &hcldec.AttrSpec{Name: "plan_slug", Type: cty.String, Required: false},
 		"image_slug":                   &hcldec.AttrSpec{Name: "image_slug", Type: cty.String, Required: false},
+		"source_snapshot_slug":         &hcldec.AttrSpec{Name: "source_snapshot_slug", Type: cty.String, Required: false},
 		"ssh_slug":                     &hcldec.AttrSpec{Name: "ssh_slug", Type: cty.String, Required: false},
 		"hostname":                     &hcldec.AttrSpec{Name: "hostname", Type: cty.String, Required: false},
 		"label":                        &hcldec.AttrSpec{Name: "label", Type: cty.String, Required: false},
 		"snapshot_name":                &hcldec.AttrSpec{Name: "snapshot_name", Type: cty.String, Required: false},
+		"shutdown_command":             &hcldec.AttrSpec{Name: "shutdown_command", Type: cty.String, Required: false},
 		"state_timeout":                &hcldec.AttrSpec{Name: "state_timeout", Type: cty.String, Required: false},
 		"keep_instance":                &hcldec.AttrSpec{Name: "keep_instance", Type: cty.Bool, Required: false},
+		"skip_create_snapshot":         &hcldec.AttrSpec{Name: "skip_create_snapshot", Type: cty.Bool, Required: false},
+		"password_output_file":         &hcldec.AttrSpec{Name: "password_output_file", Type: cty.String, Required: false},
+		"instance_create_timeout":      &hcldec.AttrSpec{Name: "instance_create_timeout", Type: cty.String, Required: false},
+		"shutdown_timeout":             &hcldec.AttrSpec{Name: "shutdown_timeout", Type: cty.String, Required: false},
+		"snapshot_timeout":             &hcldec.AttrSpec{Name: "snapshot_timeout", Type: cty.String, Required: false},
+		"api_max_retries":              &hcldec.AttrSpec{Name: "api_max_retries", Type: cty.Number, Required: false},
+		"api_retry_max_backoff":        &hcldec.AttrSpec{Name: "api_retry_max_backoff", Type: cty.String, Required: false},
+		"api_requests_per_second":      &hcldec.AttrSpec{Name: "api_requests_per_second", Type: cty.Number, Required: false},
 	}
 	return s
 }
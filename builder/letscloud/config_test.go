@@ -0,0 +1,299 @@
+package letscloud
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigPrepare_apiURL(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		env     string
+		want    string
+		wantErr bool
+	}{
+		{name: "unset", want: ""},
+		{name: "explicit", value: "https://staging.example.com/api/", want: "https://staging.example.com/api"},
+		{name: "env fallback", env: "http://127.0.0.1:8080", want: "http://127.0.0.1:8080"},
+		{name: "explicit wins over env", value: "https://a.example.com", env: "https://b.example.com", want: "https://a.example.com"},
+		{name: "relative", value: "/api", wantErr: true},
+		{name: "bad scheme", value: "ftp://example.com", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LETSCLOUD_API_URL", tc.env)
+
+			raw := testConfig()
+			if tc.value != "" {
+				raw["api_url"] = tc.value
+			}
+
+			var c Config
+			err := c.Prepare(raw)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.APIURL != tc.want {
+				t.Errorf("expected api_url %q, got %q", tc.want, c.APIURL)
+			}
+		})
+	}
+}
+
+func TestConfigPrepare_apiKeySources(t *testing.T) {
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+	err := os.WriteFile(credentials, []byte(`
+# LetsCloud accounts
+[default]
+api_key = default-key
+
+[staging]
+api_key = "staging-key"
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		raw       map[string]interface{}
+		env       string
+		want      string
+		wantError string
+	}{
+		{
+			name: "template wins",
+			raw:  map[string]interface{}{"api_key": "template-key", "credentials_file": credentials},
+			env:  "env-key",
+			want: "template-key",
+		},
+		{
+			name: "environment",
+			raw:  map[string]interface{}{"credentials_file": credentials},
+			env:  "env-key",
+			want: "env-key",
+		},
+		{
+			name: "default profile",
+			raw:  map[string]interface{}{"credentials_file": credentials},
+			want: "default-key",
+		},
+		{
+			name: "named profile",
+			raw:  map[string]interface{}{"credentials_file": credentials, "profile": "staging"},
+			want: "staging-key",
+		},
+		{
+			name: "named profile wins over environment",
+			raw:  map[string]interface{}{"credentials_file": credentials, "profile": "staging"},
+			env:  "env-key",
+			want: "staging-key",
+		},
+		{
+			name:      "unknown profile",
+			raw:       map[string]interface{}{"credentials_file": credentials, "profile": "prod"},
+			wantError: `profile "prod" not found`,
+		},
+		{
+			name:      "missing file",
+			raw:       map[string]interface{}{"credentials_file": filepath.Join(dir, "nope")},
+			wantError: "LETSCLOUD_API_KEY",
+		},
+		{
+			name:      "missing file with named profile",
+			raw:       map[string]interface{}{"credentials_file": filepath.Join(dir, "nope"), "profile": "staging"},
+			env:       "env-key",
+			wantError: "tried the `api_key` option and the credentials file",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("LETSCLOUD_API_KEY", tc.env)
+			t.Setenv("LETSCLOUD_PROFILE", "")
+
+			raw := testConfig()
+			delete(raw, "api_key")
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+
+			var c Config
+			err := c.Prepare(raw)
+			if tc.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantError) {
+					t.Fatalf("expected error containing %q, got %v", tc.wantError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if c.APIKey != tc.want {
+				t.Errorf("expected api key %q, got %q", tc.want, c.APIKey)
+			}
+		})
+	}
+}
+
+func TestConfigPrepare_sourceImage(t *testing.T) {
+	cases := []struct {
+		name     string
+		image    string
+		snapshot string
+		want     string
+		wantErr  bool
+	}{
+		{name: "image", image: "ubuntu-24.04-x86_64", want: "ubuntu-24.04-x86_64"},
+		{name: "snapshot", snapshot: "snap-1", want: "snap-1"},
+		{name: "both", image: "ubuntu-24.04-x86_64", snapshot: "snap-1", wantErr: true},
+		{name: "neither", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := testConfig()
+			raw["image_slug"] = tc.image
+			raw["source_snapshot_slug"] = tc.snapshot
+
+			var c Config
+			err := c.Prepare(raw)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := c.SourceImage(); got != tc.want {
+				t.Errorf("expected source image %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestConfigPrepare_temporaryKeyPairType(t *testing.T) {
+	var c Config
+	if err := c.Prepare(testConfig()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Comm.SSHTemporaryKeyPairType != "ed25519" {
+		t.Errorf("expected ed25519 keys by default, got %q", c.Comm.SSHTemporaryKeyPairType)
+	}
+
+	for _, value := range []string{"ed25519", "rsa"} {
+		raw := testConfig()
+		raw["temporary_key_pair_type"] = value
+		var c Config
+		if err := c.Prepare(raw); err != nil {
+			t.Errorf("expected %q to be accepted, got %s", value, err)
+		}
+	}
+
+	raw := testConfig()
+	raw["temporary_key_pair_type"] = "dsa"
+	c = Config{}
+	if err := c.Prepare(raw); err == nil || !strings.Contains(err.Error(), "temporary_key_pair_type") {
+		t.Errorf("expected dsa keys to be rejected, got %v", err)
+	}
+}
+
+func TestConfigPrepare_timeouts(t *testing.T) {
+	raw := testConfig()
+	raw["state_timeout"] = "20m"
+	raw["snapshot_timeout"] = "1h"
+
+	var c Config
+	if err := c.Prepare(raw); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.instanceCreateTimeout != 20*time.Minute {
+		t.Errorf("expected instance_create_timeout to fall back to state_timeout, got %s", c.instanceCreateTimeout)
+	}
+	if c.shutdownTimeout != 20*time.Minute {
+		t.Errorf("expected shutdown_timeout to fall back to state_timeout, got %s", c.shutdownTimeout)
+	}
+	if c.snapshotTimeout != time.Hour {
+		t.Errorf("expected snapshot_timeout of 1h, got %s", c.snapshotTimeout)
+	}
+
+	for _, field := range []string{"state_timeout", "instance_create_timeout", "shutdown_timeout", "snapshot_timeout"} {
+		for _, value := range []string{"soon", "-1m", "0s"} {
+			raw := testConfig()
+			raw[field] = value
+
+			var c Config
+			if err := c.Prepare(raw); err == nil || !strings.Contains(err.Error(), field) {
+				t.Errorf("expected %s = %q to be rejected, got %v", field, value, err)
+			}
+		}
+	}
+}
+
+func TestConfigPrepare_retry(t *testing.T) {
+	var c Config
+	if err := c.Prepare(testConfig()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.retry.maxRetries != defaultAPIMaxRetries || c.retry.maxBackoff != defaultAPIRetryMaxBackoff {
+		t.Errorf("expected the default retry policy, got %+v", c.retry)
+	}
+
+	raw := testConfig()
+	raw["api_max_retries"] = 0
+	raw["api_retry_max_backoff"] = "5s"
+	c = Config{}
+	if err := c.Prepare(raw); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.retry.maxRetries != 0 || c.retry.maxBackoff != 5*time.Second {
+		t.Errorf("expected retries to be disabled with a 5s backoff, got %+v", c.retry)
+	}
+
+	invalid := map[string]interface{}{
+		"api_max_retries":       -1,
+		"api_retry_max_backoff": "0s",
+	}
+	for field, value := range invalid {
+		raw := testConfig()
+		raw[field] = value
+
+		var c Config
+		if err := c.Prepare(raw); err == nil || !strings.Contains(err.Error(), field) {
+			t.Errorf("expected %s = %v to be rejected, got %v", field, value, err)
+		}
+	}
+}
+
+func TestConfigPrepare_requestsPerSecond(t *testing.T) {
+	raw := testConfig()
+	delete(raw, "api_requests_per_second")
+
+	var c Config
+	if err := c.Prepare(raw); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.APIRequestsPerSecond != defaultAPIRequestsPerSecond {
+		t.Errorf("expected %d requests per second by default, got %v", defaultAPIRequestsPerSecond, c.APIRequestsPerSecond)
+	}
+
+	raw["api_requests_per_second"] = -1
+	c = Config{}
+	if err := c.Prepare(raw); err == nil || !strings.Contains(err.Error(), "api_requests_per_second") {
+		t.Errorf("expected a negative rate to be rejected, got %v", err)
+	}
+}
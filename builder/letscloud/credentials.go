@@ -0,0 +1,103 @@
+package letscloud
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/packer-plugin-sdk/pathing"
+)
+
+const (
+	defaultProfile         = "default"
+	defaultCredentialsFile = "~/.letscloud/credentials"
+)
+
+// credentialsFilePath returns the credentials file to read, honouring the
+// LETSCLOUD_CREDENTIALS_FILE environment variable when path is empty.
+func credentialsFilePath(path string) (string, error) {
+	if path == "" {
+		path = os.Getenv("LETSCLOUD_CREDENTIALS_FILE")
+	}
+	if path == "" {
+		path = defaultCredentialsFile
+	}
+
+	expanded, err := pathing.ExpandUser(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(expanded), nil
+}
+
+// readCredentialsFile parses an INI style credentials file into a map of
+// profile name to key/value pairs:
+//
+//	[default]
+//	api_key = xxxxxxxx
+//
+//	[staging]
+//	api_key = yyyyyyyy
+func readCredentialsFile(path string) (map[string]map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	profiles := map[string]map[string]string{}
+	var current map[string]string
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSpace(line[1 : len(line)-1])
+			if _, ok := profiles[name]; !ok {
+				profiles[name] = map[string]string{}
+			}
+			current = profiles[name]
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			return nil, fmt.Errorf("%s:%d: expected `[profile]` or `key = value`", path, n)
+		}
+		current[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return profiles, nil
+}
+
+// apiKeyFromProfile looks up the api_key of profile in the credentials file at
+// path. The returned note describes why no key was found, for use in
+// validation errors, and err is only set when the file exists but is unusable.
+func apiKeyFromProfile(path, profile string) (key string, note string, err error) {
+	profiles, err := readCredentialsFile(path)
+	if os.IsNotExist(err) {
+		return "", fmt.Sprintf("%s does not exist", path), nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("unable to read credentials file: %s", err)
+	}
+
+	values, ok := profiles[profile]
+	if !ok {
+		return "", fmt.Sprintf("profile %q not found in %s", profile, path), nil
+	}
+	if values["api_key"] == "" {
+		return "", fmt.Sprintf("profile %q in %s has no api_key", profile, path), nil
+	}
+
+	return values["api_key"], "", nil
+}
@@ -2,20 +2,28 @@
 package letscloud
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
 	"math/big"
-	"os"
+	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"crypto/rand"
 
 	"github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/letscloud-community/letscloud-go"
 	"github.com/letscloud-community/letscloud-go/domains"
 )
 
+// DefaultPollInterval is how often the waiters query the API for a state
+// change.
+const DefaultPollInterval = 10 * time.Second
+
+// pollInterval is the interval of the waiters of the builder.
+var pollInterval = DefaultPollInterval
+
 // generateRandomPassword generates a secure password with at least one lowercase letter, one uppercase letter, one number, and one special character.
 func generateRandomPassword(length int) (string, error) {
 	if length < 8 {
@@ -59,53 +67,163 @@ func shuffle(password []byte) {
 	}
 }
 
-// waitForInstanceCreation polls the Instances API to find the created instance.
-// It waits until the instance is built and not locked or suspended.
-// Returns the instance if found within the timeout period.
-func waitForInstanceCreation(ui packer.Ui, sdkClient *letscloud.LetsCloud, label string, hostname string, timeout time.Duration) (*domains.Instance, error) {
-	ticker := time.NewTicker(10 * time.Second)
+// waitForInstanceCreation polls the created instance until it is built, not
+// locked or suspended, and has an IP address. The instance is fetched by identifier, or found by
+// label and hostname when the identifier is not known.
+// Returns the instance if found within the timeout period, or ctx's error if
+// it is cancelled first.
+func waitForInstanceCreation(ctx context.Context, ui packer.Ui, client *APIClient, identifier string, label string, hostname string, timeout time.Duration) (*domains.Instance, error) {
+	ticker := time.NewTicker(pollInterval)
 	defer ticker.Stop()
 
+	start := time.Now()
 	timeoutChan := time.After(timeout)
+	// noAddress records that the instance was last seen built without an IP
+	// address.
+	noAddress := false
 
 	for {
 		select {
 		case <-ticker.C:
-			instances, err := sdkClient.Instances()
+			var inst *domains.Instance
+			var err error
+			if identifier != "" {
+				inst, err = client.Instance(ctx, identifier)
+			} else {
+				inst, err = findInstance(ctx, client, label, hostname)
+			}
 			if err != nil {
-				ui.Error(fmt.Sprintf("Failed to list instances: %s", err))
+				ui.Error(fmt.Sprintf("Failed to look up instance: %s", err))
 				return nil, err
 			}
 
-			for _, inst := range instances {
-				if inst.Label == label && inst.Hostname == hostname {
-					// Instance matches label and hostname.
-					// Check if it is built and not locked or suspended.
-					if inst.Built && !inst.Locked && !inst.Suspended {
-						return &inst, nil
+			if inst != nil {
+				// Check if it is built and not locked or suspended.
+				if inst.Built && !inst.Locked && !inst.Suspended {
+					if len(inst.IPAddresses) > 0 {
+						return inst, nil
 					}
-					ui.Message("Instance is not yet built or still locked. Waiting...")
+					ui.Message("Instance is built but has no IP address yet. Waiting...")
+					noAddress = true
+					continue
 				}
+				ui.Message("Instance is not yet built or still locked. Waiting...")
 			}
 		case <-timeoutChan:
-			return nil, fmt.Errorf("timed out waiting for instance to be built")
+			if noAddress {
+				return nil, fmt.Errorf("timed out after %s waiting for instance to get an IP address", elapsed(start))
+			}
+			return nil, fmt.Errorf("timed out after %s waiting for instance to be built", elapsed(start))
+		case <-ctx.Done():
+			return nil, fmt.Errorf("cancelled after %s waiting for instance to be built: %w", elapsed(start), ctx.Err())
+		}
+	}
+}
+
+// createOnce calls create, which sends a request that creates a resource.
+// When the request fails in a way the API may have acted on anyway, find
+// reports whether the resource exists, and create is only called again when
+// it does not, so that retrying never leaves a duplicate behind. Both
+// closures store the resource themselves.
+func createOnce(ctx context.Context, ui packer.Ui, client *APIClient, what string, create func() error, find func() (bool, error)) error {
+	for attempt := 0; ; attempt++ {
+		err := create()
+		if err == nil || !mayHaveSucceeded(err) {
+			return err
+		}
+
+		ui.Message(fmt.Sprintf("Creating the %s failed (%s); checking whether it was created anyway.", what, err))
+		found, findErr := find()
+		if findErr != nil {
+			ui.Message(fmt.Sprintf("Unable to check whether the %s was created: %s", what, findErr))
+			return err
+		}
+		if found {
+			return nil
+		}
+		if ctx.Err() != nil || attempt >= client.retry.maxRetries {
+			return err
+		}
+
+		delay := client.retry.backoff(attempt)
+		ui.Message(fmt.Sprintf("The %s was not created; trying again in %s.", what, delay))
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// findSSHKey returns the SSH key with the given title, or nil if there is
+// none. The builder gives its keys unique titles.
+func findSSHKey(ctx context.Context, client *APIClient, title string) (*domains.SSHKey, error) {
+	keys, err := client.SSHKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, key := range keys {
+		if key.Title == title {
+			return &key, nil
 		}
 	}
+	return nil, nil
 }
 
-func waitForSnapshotCreation(ui packer.Ui, sdkClient *letscloud.LetsCloud, slug string, timeout time.Duration) error {
+// findNewSnapshot returns the snapshot with the given label that is still
+// being built, or nil if there is none. Finished snapshots are left out, as
+// they may be older ones of the same name.
+func findNewSnapshot(ctx context.Context, client *APIClient, label string) (*domains.Snapshot, error) {
+	snapshots, err := client.Snapshots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, snap := range snapshots {
+		if snap.Label == label && !snap.Build {
+			return &snap, nil
+		}
+	}
+	return nil, nil
+}
+
+// findInstance returns the instance matching label and hostname, or nil if
+// there is none.
+func findInstance(ctx context.Context, client *APIClient, label string, hostname string) (*domains.Instance, error) {
+	instances, err := client.Instances(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, inst := range instances {
+		if inst.Label == label && inst.Hostname == hostname {
+			return &inst, nil
+		}
+	}
+	return nil, nil
+}
+
+// WaitForSnapshot polls the snapshot every interval until it is built,
+// giving up after timeout, when ctx is cancelled, or when the API answers with an error that
+// retrying will not fix, such as the snapshot not being found.
+func WaitForSnapshot(ctx context.Context, ui packer.Ui, client *APIClient, slug string, interval, timeout time.Duration) error {
 	ui.Say(fmt.Sprintf("Waiting for snapshot '%s' to finish building...", slug))
 
-	ticker := time.NewTicker(10 * time.Second)
+	ticker := time.NewTicker(interval)
 	defer ticker.Stop()
 
+	start := time.Now()
 	timeoutChan := time.After(timeout)
 
 	for {
 		select {
 		case <-ticker.C:
-			snapshot, err := sdkClient.Snapshot(slug)
+			snapshot, err := client.Snapshot(ctx, slug)
 			if err != nil {
+				if !retryable(http.MethodGet, err) {
+					return fmt.Errorf("unable to check snapshot '%s': %w", slug, err)
+				}
 				ui.Message(fmt.Sprintf("Error checking snapshot status: %s", err))
 				continue
 			}
@@ -117,21 +235,123 @@ func waitForSnapshotCreation(ui packer.Ui, sdkClient *letscloud.LetsCloud, slug
 			ui.Message("Snapshot still building...")
 
 		case <-timeoutChan:
-			return fmt.Errorf("snapshot '%s' did not finish building within %s", slug, timeout.String())
+			return fmt.Errorf("snapshot '%s' did not finish building within %s (waited %s)", slug, timeout, elapsed(start))
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled after %s waiting for snapshot '%s': %w", elapsed(start), slug, ctx.Err())
 		}
 	}
 }
 
-// savePrivateKeyToFile saves the private key to a temporary file and returns its path.
-func savePrivateKeyToFile(privateKey string) string {
-	timestamp := time.Now().Unix()
-	keyPath := fmt.Sprintf("/tmp/packer_ssh_key_%d.pem", timestamp)
+// CopySnapshotAndWait copies the snapshot to each of locations in parallel,
+// and waits until it is available in all of them, giving up on a location
+// after timeout. Failed copies are returned as a *packer.MultiError.
+func CopySnapshotAndWait(ctx context.Context, ui packer.Ui, client *APIClient, slug string, locations []string, interval, timeout time.Duration) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs *packer.MultiError
+	)
+	for _, loc := range locations {
+		wg.Add(1)
+		go func(loc string) {
+			defer wg.Done()
 
-	err := os.WriteFile(keyPath, []byte(privateKey), 0600)
-	if err != nil {
-		log.Fatalf("Error saving private key: %s", err)
+			ui.Say(fmt.Sprintf("Copying snapshot '%s' to %s...", slug, loc))
+			err := client.CopySnapshot(ctx, slug, loc)
+			if err == nil {
+				err = waitForSnapshotCopy(ctx, ui, client, slug, loc, interval, timeout)
+			}
+			if err != nil {
+				mu.Lock()
+				errs = packer.MultiErrorAppend(errs, fmt.Errorf("failed to copy snapshot '%s' to %s: %s", slug, loc, err))
+				mu.Unlock()
+				return
+			}
+			ui.Say(fmt.Sprintf("Snapshot '%s' is available in %s.", slug, loc))
+		}(loc)
 	}
+	wg.Wait()
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// waitForSnapshotCopy polls the snapshot until it is available in the
+// location it is copied to, giving up after timeout, when ctx is cancelled,
+// or when the API answers with an error that retrying will not fix.
+func waitForSnapshotCopy(ctx context.Context, ui packer.Ui, client *APIClient, slug string, location string, interval, timeout time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	timeoutChan := time.After(timeout)
+
+	for {
+		select {
+		case <-ticker.C:
+			snapshot, err := client.Snapshot(ctx, slug)
+			if err != nil {
+				if !retryable(http.MethodGet, err) {
+					return fmt.Errorf("unable to check snapshot '%s': %w", slug, err)
+				}
+				ui.Message(fmt.Sprintf("Error checking snapshot status: %s", err))
+				continue
+			}
+
+			if slices.Contains(snapshot.Locations, location) {
+				return nil
+			}
+
+		case <-timeoutChan:
+			return fmt.Errorf("snapshot '%s' was not copied to %s within %s (waited %s)", slug, location, timeout, elapsed(start))
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled after %s waiting for snapshot '%s' to be copied to %s: %w", elapsed(start), slug, location, ctx.Err())
+		}
+	}
+}
+
+// waitForInstanceShutdown polls the instance until it is no longer booted,
+// giving up after timeout or when ctx is cancelled. The instance is checked
+// at least once, even if timeout is not positive.
+func waitForInstanceShutdown(ctx context.Context, ui packer.Ui, client *APIClient, identifier string, timeout time.Duration) error {
+	ui.Say(fmt.Sprintf("Waiting for instance %s to power off...", identifier))
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	start := time.Now()
+	timeoutChan := time.After(timeout)
+
+	for {
+		select {
+		case <-ticker.C:
+			inst, err := client.Instance(ctx, identifier)
+			if err != nil {
+				ui.Message(fmt.Sprintf("Error checking instance status: %s", err))
+				continue
+			}
+
+			if !inst.Booted {
+				return nil
+			}
+			ui.Message("Instance still running...")
+
+		case <-timeoutChan:
+			// The timeout may be used up before the first poll, e.g. by a
+			// slow power-off request; check once more before giving up.
+			if inst, err := client.Instance(ctx, identifier); err == nil && !inst.Booted {
+				return nil
+			}
+			return fmt.Errorf("instance %s did not power off within the shutdown timeout (waited %s)", identifier, elapsed(start))
+		case <-ctx.Done():
+			return fmt.Errorf("cancelled after %s waiting for instance %s to power off: %w", elapsed(start), identifier, ctx.Err())
+		}
+	}
+}
 
-	log.Printf("Private key saved at: %s", keyPath)
-	return keyPath
+// elapsed returns the time since start, rounded for display.
+func elapsed(start time.Time) time.Duration {
+	return time.Since(start).Round(time.Second)
 }
@@ -0,0 +1,146 @@
+package letscloud
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/filelock"
+)
+
+// defaultAPIRequestsPerSecond is the default rate of API requests per
+// account.
+const defaultAPIRequestsPerSecond = 5
+
+// rateLimiters holds the limiters of this plugin process.
+var rateLimiters = newLimiterRegistry(os.TempDir())
+
+// limiterRegistry hands out one rateLimiter per API key, keeping the
+// schedules they share with other plugin processes in dir.
+type limiterRegistry struct {
+	mu       sync.Mutex
+	dir      string
+	limiters map[string]*rateLimiter
+}
+
+func newLimiterRegistry(dir string) *limiterRegistry {
+	return &limiterRegistry{dir: dir, limiters: map[string]*rateLimiter{}}
+}
+
+// rateLimiter returns the limiter of apiKey in this plugin process. When
+// clients ask for different rates, the lowest one applies.
+func (r *limiterRegistry) rateLimiter(apiKey string, requestsPerSecond float64) *rateLimiter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	interval := time.Duration(float64(time.Second) / requestsPerSecond)
+	burst := int(math.Max(1, math.Ceil(requestsPerSecond)))
+
+	l, ok := r.limiters[apiKey]
+	if !ok {
+		sum := sha256.Sum256([]byte(apiKey))
+		path := filepath.Join(r.dir, "packer-plugin-letscloud-"+hex.EncodeToString(sum[:16])+".rate")
+		l = &rateLimiter{
+			path:     path,
+			lock:     filelock.New(path + ".lock"),
+			interval: interval,
+			burst:    burst,
+		}
+		r.limiters[apiKey] = l
+		return l
+	}
+
+	l.mu.Lock()
+	if interval > l.interval {
+		l.interval, l.burst = interval, burst
+	}
+	l.mu.Unlock()
+	return l
+}
+
+// rateLimiter spaces out the requests made with an API key. Packer runs
+// each build in a plugin process of its own, so parallel builds share the
+// schedule through a file named after a hash of the key, guarded by a file
+// lock: every request books the next slot, and at most burst requests may
+// start within one interval of each other.
+type rateLimiter struct {
+	path string
+	lock *filelock.Flock
+
+	// mu serialises the goroutines of this process, as the file lock is
+	// only exclusive between processes.
+	mu       sync.Mutex
+	interval time.Duration
+	burst    int
+	// next is the schedule used when the file cannot be.
+	next time.Time
+}
+
+// Wait blocks until the next request may be sent, or ctx is done.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	at, err := l.reserve(ctx)
+	if err != nil {
+		return err
+	}
+
+	d := time.Until(at)
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// reserve books a slot in the schedule and returns when it starts.
+func (l *rateLimiter) reserve(ctx context.Context) (time.Time, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if _, err := l.lock.TryLockContext(ctx, time.Millisecond); err != nil {
+		if ctx.Err() != nil {
+			return time.Time{}, ctx.Err()
+		}
+		log.Printf("[WARN] unable to lock %s, the API rate limit is not shared with other builds: %s", l.lock.Path(), err)
+		return l.book(l.next), nil
+	}
+	defer l.lock.Unlock()
+
+	next := l.next
+	if data, err := os.ReadFile(l.path); err == nil {
+		if ns, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil {
+			next = time.Unix(0, ns)
+		}
+	}
+
+	at := l.book(next)
+	if err := os.WriteFile(l.path, []byte(strconv.FormatInt(l.next.UnixNano(), 10)), 0600); err != nil {
+		log.Printf("[WARN] unable to write %s, the API rate limit is not shared with other builds: %s", l.path, err)
+	}
+	return at, nil
+}
+
+// book takes the slot following next and returns when it starts. Slots
+// that fell in the past are not kept, so idle time does not build up more
+// than burst requests.
+func (l *rateLimiter) book(next time.Time) time.Time {
+	now := time.Now()
+	if next.Before(now) {
+		next = now
+	}
+	l.next = next.Add(l.interval)
+	return l.next.Add(-time.Duration(l.burst) * l.interval)
+}
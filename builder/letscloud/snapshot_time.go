@@ -0,0 +1,43 @@
+package letscloud
+
+import (
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DefaultSnapshotFamily is the family of the snapshots of builds without a
+// `snapshot_name`. Every such build shares it, whatever its template.
+const DefaultSnapshotFamily = "packer-snapshot-"
+
+// snapshotTimestamp matches the unix timestamp the builder and Packer's
+// {{timestamp}} function append to snapshot names, e.g.
+// "packer-snapshot-1718000000".
+var snapshotTimestamp = regexp.MustCompile(`[-_](\d{9,})$`)
+
+// SnapshotTime returns the creation time encoded at the end of a snapshot
+// label. The LetsCloud API does not report when a snapshot was created, so
+// this is the only ordering available; ok is false for labels without one.
+func SnapshotTime(label string) (t time.Time, ok bool) {
+	m := snapshotTimestamp.FindStringSubmatch(label)
+	if m == nil {
+		return time.Time{}, false
+	}
+
+	sec, err := strconv.ParseInt(m[1], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(sec, 0), true
+}
+
+// SnapshotFamily returns the part of a snapshot label before its timestamp,
+// separator included, which every build of the same template shares, e.g.
+// "packer-snapshot-". ok is false for labels without a timestamp.
+func SnapshotFamily(label string) (family string, ok bool) {
+	m := snapshotTimestamp.FindStringSubmatchIndex(label)
+	if m == nil {
+		return "", false
+	}
+	return label[:m[2]], true
+}
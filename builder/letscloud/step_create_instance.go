@@ -3,17 +3,21 @@ package letscloud
 import (
 	"context"
 	"fmt"
+	"os"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/letscloud-community/letscloud-go"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
 	"github.com/letscloud-community/letscloud-go/domains"
 )
 
 type StepCreateInstance struct {
-	sdkClient *letscloud.LetsCloud
-	config    *Config
+	apiClient     *APIClient
+	config        *Config
+	generatedData *packerbuilderdata.GeneratedData
 }
 
 // Run executes the StepCreateInstance.
@@ -21,19 +25,27 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 	// Retrieve the Packer UI interface for user interactions.
 	ui := state.Get("ui").(packer.Ui)
 
+	if s.config.SourceSnapshotSlug != "" {
+		if err := s.checkSourceSnapshot(ctx); err != nil {
+			ui.Error(err.Error())
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+	}
+
 	ui.Say("Creating a new instance...")
 
 	// Retrieve SSHSlug and Password from the configuration.
-	sshSlug := state.Get("slugKey").(string)
+	sshSlug := state.Get("ssh_key_slug").(string)
 	password, err := generateRandomPassword(16) // Generates a 16-character password.
 	if err != nil {
 		ui.Error(fmt.Sprintf("Failed to generate password: %s", err))
 		state.Put("error", err)
 		return multistep.ActionHalt
-	} else {
-		state.Put("generated_password", password)
 	}
-	ui.Say("Generated password: " + password)
+	// Keep the password out of the UI and logs.
+	packer.LogSecretFilter.Set(password)
+	state.Put("generated_password", password)
 	timestamp := time.Now().Unix()
 	if s.config.Label == "" {
 		s.config.Label = fmt.Sprintf("packer-%d", timestamp)
@@ -50,13 +62,23 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 		PlanSlug:     s.config.PlanSlug,
 		Hostname:     s.config.Hostname,
 		Label:        s.config.Label,
-		ImageSlug:    s.config.ImageSlug,
+		ImageSlug:    s.config.SourceImage(),
 		SSHSlug:      sshSlug,
 		Password:     password,
 	}
 
-	// Create the instance using the SDK client.
-	err = s.sdkClient.CreateInstance(createReq)
+	// Create the instance using the API client.
+	var instanceID string
+	err = createOnce(ctx, ui, s.apiClient, "instance", func() (err error) {
+		instanceID, err = s.apiClient.CreateInstance(ctx, createReq)
+		return err
+	}, func() (bool, error) {
+		inst, err := findInstance(ctx, s.apiClient, s.config.Label, s.config.Hostname)
+		if inst != nil {
+			instanceID = inst.Identifier
+		}
+		return inst != nil, err
+	})
 	if err != nil {
 		ui.Error(fmt.Sprintf("Failed to create instance: %s", err))
 		state.Put("error", err)
@@ -64,25 +86,66 @@ func (s *StepCreateInstance) Run(ctx context.Context, state multistep.StateBag)
 	}
 
 	ui.Say("Instance created successfully.")
+	// Remember that the instance exists, so that Cleanup can find it even if
+	// the build is interrupted before it is ready.
+	state.Put("instance_created", true)
+	if instanceID != "" {
+		state.Put("instance_identifier", instanceID)
+	} else {
+		ui.Message("The API did not return the instance identifier; looking it up by label.")
+	}
+
+	if s.config.PasswordOutputFile != "" {
+		if err := os.WriteFile(s.config.PasswordOutputFile, []byte(password+"\n"), 0600); err != nil {
+			err = fmt.Errorf("failed to write the root password to %s: %s", s.config.PasswordOutputFile, err)
+			ui.Error(fmt.Sprintf("Failed to save the root password: %s", err))
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+		ui.Say(fmt.Sprintf("Root password written to %s", s.config.PasswordOutputFile))
+	}
 
 	// Wait for the instance to be built and retrieve its details.
-	createdInstance, err := waitForInstanceCreation(ui, s.sdkClient, s.config.Label, s.config.Hostname, 300*time.Second)
+	createdInstance, err := waitForInstanceCreation(ctx, ui, s.apiClient, instanceID, s.config.Label, s.config.Hostname, s.config.instanceCreateTimeout)
 	if err != nil {
 		ui.Error(fmt.Sprintf("Error retrieving created instance: %s", err))
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
 
-	ui.Say(fmt.Sprintf("Instance Details:\nIdentifier: %s\nIP: %s", createdInstance.Identifier, createdInstance.IPAddresses[0].Address))
+	// The waiter only returns instances with an IP address.
+	ip := createdInstance.IPAddresses[0].Address
+	ui.Say(fmt.Sprintf("Instance Details:\nIdentifier: %s\nIP: %s", createdInstance.Identifier, ip))
 
 	// Store the instance details in the state bag for later use.
 	state.Put("instance_identifier", createdInstance.Identifier)
-	state.Put("instance_ip", createdInstance.IPAddresses[0].Address)
+	state.Put("instance_ip", ip)
+	s.generatedData.Put("InstanceID", createdInstance.Identifier)
+	s.generatedData.Put("InstanceIP", ip)
 
 	return multistep.ActionContinue
 
 }
 
+// checkSourceSnapshot verifies that the source snapshot is finished and
+// available in the target location before an instance is launched from it.
+func (s *StepCreateInstance) checkSourceSnapshot(ctx context.Context) error {
+	slug := s.config.SourceSnapshotSlug
+
+	snapshot, err := s.apiClient.Snapshot(ctx, slug)
+	if err != nil {
+		return fmt.Errorf("failed to look up source snapshot '%s': %s", slug, err)
+	}
+	if !snapshot.Build {
+		return fmt.Errorf("source snapshot '%s' is still building", slug)
+	}
+	if len(snapshot.Locations) > 0 && !slices.Contains(snapshot.Locations, s.config.LocationSlug) {
+		return fmt.Errorf("source snapshot '%s' is not available in location '%s' (available in: %s)",
+			slug, s.config.LocationSlug, strings.Join(snapshot.Locations, ", "))
+	}
+	return nil
+}
+
 // Cleanup is called after Run completes, whether it succeeded or failed.
 func (s *StepCreateInstance) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packer.Ui)
@@ -94,14 +157,24 @@ func (s *StepCreateInstance) Cleanup(state multistep.StateBag) {
 
 	identifier, ok := state.GetOk("instance_identifier")
 	if !ok {
-		ui.Say("No instance found to clean up.")
-		return
+		if _, created := state.GetOk("instance_created"); !created {
+			ui.Say("No instance found to clean up.")
+			return
+		}
+
+		// The build stopped while waiting for the instance; look it up.
+		inst, err := findInstance(context.Background(), s.apiClient, s.config.Label, s.config.Hostname)
+		if err != nil || inst == nil {
+			ui.Error(fmt.Sprintf("Unable to find instance '%s' to clean up, please delete it manually: %v", s.config.Label, err))
+			return
+		}
+		identifier = inst.Identifier
 	}
 	instanceID := identifier.(string)
 
 	ui.Say(fmt.Sprintf("Destroying instance: %s", instanceID))
 
-	err := s.sdkClient.DeleteInstance(instanceID)
+	err := s.apiClient.DeleteInstance(context.Background(), instanceID)
 	if err != nil {
 		ui.Error(fmt.Sprintf("Failed to delete instance %s: %s", instanceID, err))
 	} else {
@@ -3,17 +3,24 @@ package letscloud
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/letscloud-community/letscloud-go"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/letscloud-community/letscloud-go/domains"
 )
 
-// StepCreateSSHKey defines a step to create a new SSH key.
+// StepCreateSSHKey registers the public key the communicator connects with,
+// unless an existing key is given with ssh_slug. The key pair is generated
+// locally, or read from ssh_private_key_file, so the private key never
+// leaves this machine.
 type StepCreateSSHKey struct {
-	sdkClient *letscloud.LetsCloud
-	config    *Config
+	apiClient     *APIClient
+	config        *Config
+	generatedData *packerbuilderdata.GeneratedData
 }
 
 // Run executes the StepCreateSSHKey.
@@ -23,33 +30,87 @@ func (s *StepCreateSSHKey) Run(ctx context.Context, state multistep.StateBag) mu
 	if s.config.SSHSlug != "" {
 		ui.Say("Using provided SSH key slug: " + s.config.SSHSlug)
 		state.Put("ssh_key_slug", s.config.SSHSlug)
+		s.generatedData.Put("SSHKeySlug", s.config.SSHSlug)
 
 		return multistep.ActionContinue
 	}
 
-	ui.Say("Creating a new SSH key...")
+	publicKey, err := s.keyPair(ui)
+	if err != nil {
+		ui.Error(err.Error())
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	ui.Say("Uploading the public SSH key...")
 
 	timestamp := time.Now().Unix()
 	sshKeyTitle := fmt.Sprintf("packer-ssh-key-%d", timestamp)
 
-	sshKey, err := s.sdkClient.NewSSHKey(sshKeyTitle, "")
+	var sshKey *domains.SSHKey
+	err = createOnce(ctx, ui, s.apiClient, "SSH key", func() (err error) {
+		sshKey, err = s.apiClient.CreateSSHKey(ctx, sshKeyTitle, publicKey)
+		return err
+	}, func() (bool, error) {
+		key, err := findSSHKey(ctx, s.apiClient, sshKeyTitle)
+		if key != nil {
+			sshKey = key
+		}
+		return key != nil, err
+	})
 	if err != nil {
 		ui.Error(fmt.Sprintf("Failed to create SSH key: %s", err))
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
-
-	s.config.Comm.SSHPrivateKeyFile = savePrivateKeyToFile(sshKey.PrivateKey)
 	ui.Say("SSH key created successfully.")
 
 	// Store SSH key details in the state bag for later use.
-	state.Put("slugKey", sshKey.Slug)
-	state.Put("publicKey", sshKey.PublicKey)
-	state.Put("privateKey", sshKey.PrivateKey)
+	state.Put("ssh_key_slug", sshKey.Slug)
+	state.Put("ssh_key_created", true)
+	s.generatedData.Put("SSHKeySlug", sshKey.Slug)
 
 	return multistep.ActionContinue
 }
 
+// keyPair loads the key pair of ssh_private_key_file, or generates a
+// temporary one of temporary_key_pair_type, into the communicator
+// configuration and returns the public key in authorized_keys format.
+func (s *StepCreateSSHKey) keyPair(ui packer.Ui) (string, error) {
+	comm := &s.config.Comm
+
+	if comm.SSHPrivateKeyFile != "" {
+		ui.Say("Using the public key of ssh_private_key_file...")
+		privateKey, err := comm.ReadSSHPrivateKeyFile()
+		if err != nil {
+			return "", err
+		}
+		publicKey, err := sshkey.PublicKeyFromPrivate(privateKey)
+		if err != nil {
+			return "", fmt.Errorf("unable to read the public key of ssh_private_key_file: %s", err)
+		}
+		comm.SSHPrivateKey = privateKey
+		comm.SSHPublicKey = publicKey
+		return strings.TrimSpace(string(publicKey)), nil
+	}
+
+	algorithm, err := sshkey.AlgorithmString(comm.SSHTemporaryKeyPairType)
+	if err != nil {
+		return "", err
+	}
+
+	ui.Say(fmt.Sprintf("Creating temporary %s SSH key...", strings.ToUpper(algorithm.String())))
+	pair, err := sshkey.GeneratePair(algorithm, nil, comm.SSHTemporaryKeyPairBits)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temporary SSH key: %s", err)
+	}
+
+	// The private key is only kept in memory, for the communicator.
+	comm.SSHPrivateKey = pair.Private
+	comm.SSHPublicKey = pair.Public
+	return strings.TrimSpace(string(pair.Public)), nil
+}
+
 // Cleanup is called after Run completes, whether it succeeded or failed.
 func (s *StepCreateSSHKey) Cleanup(state multistep.StateBag) {
 	ui := state.Get("ui").(packer.Ui)
@@ -59,15 +120,15 @@ func (s *StepCreateSSHKey) Cleanup(state multistep.StateBag) {
 		return
 	}
 
-	sshKeySlug, ok := state.GetOk("slugKey")
-	if !ok {
+	if _, ok := state.GetOk("ssh_key_created"); !ok {
 		// SSH key was not created; nothing to clean up.
 		return
 	}
+	sshKeySlug := state.Get("ssh_key_slug").(string)
 
 	ui.Say("Deleting temporary SSH key...")
 
-	err := s.sdkClient.DeleteSSHKey(sshKeySlug.(string))
+	err := s.apiClient.DeleteSSHKey(context.Background(), sshKeySlug)
 
 	if err != nil {
 		ui.Error(fmt.Sprintf("Failed to delete SSH key (slug: %s): %s", sshKeySlug, err))
@@ -0,0 +1,139 @@
+package letscloud
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/communicator/sshkey"
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/testutil"
+)
+
+func TestStepCreateSSHKey(t *testing.T) {
+	cases := []struct {
+		name string
+		// configuration overrides
+		raw map[string]interface{}
+		// prefix of the uploaded public key
+		wantKey string
+	}{
+		{name: "ed25519 by default", wantKey: "ssh-ed25519 "},
+		{name: "rsa", raw: map[string]interface{}{"temporary_key_pair_type": "rsa", "temporary_key_pair_bits": 2048}, wantKey: "ssh-rsa "},
+		{name: "private key file", raw: map[string]interface{}{"ssh_private_key_file": ""}, wantKey: "ecdsa-sha2-nistp521 "},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+
+			raw := testConfig()
+			raw["communicator"] = "ssh"
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+			var fileKey []byte
+			if _, ok := raw["ssh_private_key_file"]; ok {
+				pair, err := sshkey.GeneratePair(sshkey.ECDSA, nil, 521)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fileKey = pair.Private
+				path := filepath.Join(t.TempDir(), "id_ecdsa")
+				if err := os.WriteFile(path, fileKey, 0600); err != nil {
+					t.Fatal(err)
+				}
+				raw["ssh_private_key_file"] = path
+			}
+
+			var c Config
+			if err := c.Prepare(raw); err != nil {
+				t.Fatalf("unexpected prepare error: %s", err)
+			}
+
+			ui, out := testutil.Ui()
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", ui)
+			step := &StepCreateSSHKey{
+				apiClient:     testAPIClient(server, 0),
+				config:        &c,
+				generatedData: &packerbuilderdata.GeneratedData{State: state},
+			}
+			if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("expected the step to continue, got %v: %v\n%s", action, state.Get("error"), out)
+			}
+
+			keys := server.SSHKeys()
+			if len(keys) != 1 {
+				t.Fatalf("expected one SSH key, got %d", len(keys))
+			}
+			if !strings.HasPrefix(keys[0].PublicKey, tc.wantKey) {
+				t.Errorf("expected a %q public key to be uploaded, got %q", tc.wantKey, keys[0].PublicKey)
+			}
+			if keys[0].PublicKey != strings.TrimSpace(string(c.Comm.SSHPublicKey)) {
+				t.Errorf("expected the communicator's public key to be uploaded, got %q", keys[0].PublicKey)
+			}
+			if keys[0].PrivateKey != "" {
+				t.Error("expected the API not to generate the private key")
+			}
+			if state.Get("ssh_key_slug") != keys[0].Slug {
+				t.Errorf("expected ssh_key_slug %q, got %v", keys[0].Slug, state.Get("ssh_key_slug"))
+			}
+
+			if len(c.Comm.SSHPrivateKey) == 0 {
+				t.Error("expected the private key to be given to the communicator")
+			}
+			if fileKey != nil && string(c.Comm.SSHPrivateKey) != string(fileKey) {
+				t.Error("expected the private key of ssh_private_key_file to be used")
+			}
+			if fileKey == nil && c.Comm.SSHPrivateKeyFile != "" {
+				t.Errorf("expected the private key to stay in memory, got file %q", c.Comm.SSHPrivateKeyFile)
+			}
+
+			step.Cleanup(state)
+			if n := len(server.SSHKeys()); n != 0 {
+				t.Errorf("expected the SSH key to be deleted, %d left", n)
+			}
+		})
+	}
+}
+
+func TestStepCreateSSHKey_sshSlug(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+
+	client := testAPIClient(server, 0)
+	key, err := client.CreateSSHKey(context.Background(), "existing", "ssh-ed25519 AAAAexisting")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ui, out := testutil.Ui()
+	state := new(multistep.BasicStateBag)
+	state.Put("ui", ui)
+	step := &StepCreateSSHKey{
+		apiClient:     client,
+		config:        &Config{SSHSlug: key.Slug},
+		generatedData: &packerbuilderdata.GeneratedData{State: state},
+	}
+	if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+		t.Fatalf("expected the step to continue, got %v: %v\n%s", action, state.Get("error"), out)
+	}
+	if state.Get("ssh_key_slug") != key.Slug {
+		t.Errorf("expected ssh_key_slug %q, got %v", key.Slug, state.Get("ssh_key_slug"))
+	}
+
+	step.Cleanup(state)
+	if n := server.Calls("POST /sshkeys"); n != 1 {
+		t.Errorf("expected no new SSH key, got %d creations", n)
+	}
+	if n := len(server.SSHKeys()); n != 1 {
+		t.Errorf("expected the provided SSH key to be kept, got %d keys", n)
+	}
+}
@@ -3,16 +3,17 @@ package letscloud
 import (
 	"context"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/letscloud-community/letscloud-go"
 )
 
-// StepShutdown shuts down the instance after provisioning.
+// StepShutdown shuts down the instance after provisioning and waits for it
+// to stop, so that the snapshot is taken from a consistent disk.
 type StepShutdown struct {
-	sdkClient *letscloud.LetsCloud
+	apiClient *APIClient
 	config    *Config
 }
 
@@ -28,16 +29,37 @@ func (s *StepShutdown) Run(ctx context.Context, state multistep.StateBag) multis
 	}
 	instanceID := identifier.(string)
 
+	if s.config.ShutdownCommand != "" {
+		stopped, err := s.gracefulShutdown(ctx, state, instanceID)
+		if err != nil {
+			ui.Error(err.Error())
+			state.Put("error", err)
+			return multistep.ActionHalt
+		}
+		if stopped {
+			ui.Say(fmt.Sprintf("Instance %s has shut down gracefully.", instanceID))
+			return multistep.ActionContinue
+		}
+	}
+
 	ui.Say(fmt.Sprintf("Shutting down instance: %s", instanceID))
 
-	// Call the LetsCloud API to power off the instance
-	// Increase timeout to 60 seconds
-	if err := s.sdkClient.SetTimeout(60 * time.Second); err != nil {
-		ui.Error(fmt.Sprintf("Failed to set timeout: %v", err))
-	}
-	err := s.sdkClient.PowerOffInstance(instanceID)
+	// Call the LetsCloud API to power off the instance, bounded by
+	// shutdown_timeout rather than the default request timeout.
+	start := time.Now()
+	err := s.apiClient.withTimeout(s.config.shutdownTimeout).PowerOffInstance(ctx, instanceID)
 	if err != nil {
-		ui.Error(fmt.Sprintf("Failed to shut down instance %s: %s", instanceID, err))
+		ui.Error(fmt.Sprintf("Failed to shut down instance %s after %s: %s", instanceID, elapsed(start), err))
+		state.Put("error", err)
+		return multistep.ActionHalt
+	}
+
+	// The power-off request only starts the shutdown; wait for it to finish
+	// within what is left of shutdown_timeout. The instance is checked at
+	// least once, even if the request used up all of it.
+	remaining := s.config.shutdownTimeout - time.Since(start)
+	if err := waitForInstanceShutdown(ctx, ui, s.apiClient, instanceID, remaining); err != nil {
+		ui.Error(err.Error())
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
@@ -46,6 +68,42 @@ func (s *StepShutdown) Run(ctx context.Context, state multistep.StateBag) multis
 	return multistep.ActionContinue
 }
 
+// gracefulShutdown runs shutdown_command through the communicator and waits
+// for the instance to stop. It reports whether the instance stopped; if it
+// did not, the caller falls back to powering it off through the API. An
+// error is only returned when the build was cancelled.
+func (s *StepShutdown) gracefulShutdown(ctx context.Context, state multistep.StateBag, instanceID string) (bool, error) {
+	ui := state.Get("ui").(packer.Ui)
+
+	comm, ok := state.Get("communicator").(packer.Communicator)
+	if !ok || comm == nil {
+		ui.Message("No communicator available to run the shutdown command; powering off through the API.")
+		return false, nil
+	}
+
+	ui.Say("Gracefully shutting down instance...")
+	cmd := &packer.RemoteCmd{Command: s.config.ShutdownCommand}
+	if err := cmd.RunWithUi(ctx, comm, ui); err != nil {
+		// The connection commonly drops while the guest shuts down, so
+		// carry on and watch the instance state instead.
+		log.Printf("[WARN] shutdown command: %s", err)
+	} else if status := cmd.ExitStatus(); status != 0 && status != packer.CmdDisconnect {
+		ui.Message(fmt.Sprintf("Shutdown command exited with status %d; powering off through the API.", status))
+		return false, nil
+	}
+
+	err := waitForInstanceShutdown(ctx, ui, s.apiClient, instanceID, s.config.shutdownTimeout)
+	if err == nil {
+		return true, nil
+	}
+	if ctx.Err() != nil {
+		return false, err
+	}
+
+	ui.Message(fmt.Sprintf("%s; powering off through the API.", err))
+	return false, nil
+}
+
 func (s *StepShutdown) Cleanup(state multistep.StateBag) {
 	// No cleanup needed after shutdown
 }
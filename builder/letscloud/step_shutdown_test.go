@@ -0,0 +1,123 @@
+package letscloud
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/multistep"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/testutil"
+)
+
+// shutdownCommunicator stops the instance on the fake API when a command is
+// run, as a working shutdown_command would.
+type shutdownCommunicator struct {
+	packer.MockCommunicator
+	server     *fakeapi.Server
+	instanceID string
+}
+
+func (c *shutdownCommunicator) Start(ctx context.Context, rc *packer.RemoteCmd) error {
+	c.server.Shutdown(c.instanceID)
+	return c.MockCommunicator.Start(ctx, rc)
+}
+
+func TestStepShutdown(t *testing.T) {
+	cases := []struct {
+		name string
+		// whether a communicator is available, and whether running the
+		// shutdown command actually stops the instance
+		comm, stops bool
+		// expected number of power-off requests
+		powerOffs int
+	}{
+		{name: "graceful", comm: true, stops: true, powerOffs: 0},
+		{name: "command does not stop the instance", comm: true, stops: false, powerOffs: 1},
+		{name: "no communicator", comm: false, powerOffs: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := fakeapi.NewServer()
+			defer server.Close()
+
+			client := testAPIClient(server, 0)
+			id, err := client.CreateInstance(context.Background(), &domains.CreateInstanceRequest{
+				LocationSlug: "mia1", PlanSlug: "1vcpu-1gb-10ssd", ImageSlug: "ubuntu-24.04-x86_64",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if _, err := client.Instance(context.Background(), id); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			ui, out := testutil.Ui()
+			state := new(multistep.BasicStateBag)
+			state.Put("ui", ui)
+			state.Put("instance_identifier", id)
+
+			mock := &shutdownCommunicator{server: server}
+			if tc.stops {
+				mock.instanceID = id
+			}
+			if tc.comm {
+				state.Put("communicator", mock)
+			}
+
+			step := &StepShutdown{
+				apiClient: client,
+				config: &Config{
+					ShutdownCommand: "shutdown -P now",
+					shutdownTimeout: 100 * time.Millisecond,
+				},
+			}
+			if action := step.Run(context.Background(), state); action != multistep.ActionContinue {
+				t.Fatalf("expected the step to continue, got %v: %v\n%s", action, state.Get("error"), out)
+			}
+
+			if tc.comm && (mock.StartCmd == nil || mock.StartCmd.Command != "shutdown -P now") {
+				t.Errorf("expected the shutdown command to be run, got %+v", mock.StartCmd)
+			}
+			if n := server.Calls("PUT /instances/{id}/power-off"); n != tc.powerOffs {
+				t.Errorf("expected %d power-off requests, got %d\n%s", tc.powerOffs, n, out)
+			}
+			inst, err := client.Instance(context.Background(), id)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if inst.Booted {
+				t.Errorf("expected the instance to be stopped\n%s", out)
+			}
+		})
+	}
+}
+
+func TestWaitForInstanceShutdown_noTimeLeft(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.PowerOffPolls = 0
+
+	client := testAPIClient(server, 0)
+	id, err := client.CreateInstance(context.Background(), &domains.CreateInstanceRequest{
+		LocationSlug: "mia1", PlanSlug: "1vcpu-1gb-10ssd", ImageSlug: "ubuntu-24.04-x86_64",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := client.Instance(context.Background(), id); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if err := client.PowerOffInstance(context.Background(), id); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	ui, _ := testutil.Ui()
+	if err := waitForInstanceShutdown(context.Background(), ui, client, id, -time.Second); err != nil {
+		t.Errorf("expected the stopped instance to be seen despite the used up timeout, got %s", err)
+	}
+}
@@ -7,12 +7,14 @@ import (
 
 	"github.com/hashicorp/packer-plugin-sdk/multistep"
 	"github.com/hashicorp/packer-plugin-sdk/packer"
-	"github.com/letscloud-community/letscloud-go"
+	"github.com/hashicorp/packer-plugin-sdk/packerbuilderdata"
+	"github.com/letscloud-community/letscloud-go/domains"
 )
 
 type StepSnapshot struct {
-	sdkClient *letscloud.LetsCloud
-	config    *Config
+	apiClient     *APIClient
+	config        *Config
+	generatedData *packerbuilderdata.GeneratedData
 }
 
 // Run executes the StepSnapshot
@@ -29,30 +31,38 @@ func (s *StepSnapshot) Run(ctx context.Context, state multistep.StateBag) multis
 
 	label := s.config.SnapshotName
 	if label == "" {
-		label = fmt.Sprintf("packer-snapshot-%d", time.Now().Unix())
+		label = fmt.Sprintf("%s%d", DefaultSnapshotFamily, time.Now().Unix())
 	}
 	ui.Say(fmt.Sprintf("Requesting snapshot for instance '%s' with label '%s'...", instanceID, label))
 
-	if err := s.sdkClient.SetTimeout(60 * time.Second); err != nil {
-		ui.Error(fmt.Sprintf("Failed to set timeout: %v", err))
-	}
-	snapshot, err := s.sdkClient.NewSnapshot(label, instanceID)
+	var snapshot *domains.Snapshot
+	err := createOnce(ctx, ui, s.apiClient, "snapshot", func() (err error) {
+		snapshot, err = s.apiClient.CreateSnapshot(ctx, instanceID, label)
+		return err
+	}, func() (bool, error) {
+		snap, err := findNewSnapshot(ctx, s.apiClient, label)
+		if snap != nil {
+			snapshot = snap
+		}
+		return snap != nil, err
+	})
 	if err != nil {
 		ui.Error(fmt.Sprintf("Failed to create snapshot %s", err))
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
-	slug := snapshot.Data.Slug
+	slug := snapshot.Slug
 	ui.Say(fmt.Sprintf("Snapshot '%s' creation has been queued. Waiting for it to finish...", slug))
 
-	err = waitForSnapshotCreation(ui, s.sdkClient, slug, 10*time.Minute)
+	err = WaitForSnapshot(ctx, ui, s.apiClient, slug, pollInterval, s.config.snapshotTimeout)
 	if err != nil {
-		ui.Error(err.Error())
+		ui.Error(fmt.Sprintf("Error waiting for snapshot: %s", err))
 		state.Put("error", err)
 		return multistep.ActionHalt
 	}
-	state.Put("snapshot_name", s.config.SnapshotName)
+	state.Put("snapshot_name", label)
 	state.Put("snapshot_slug", slug)
+	s.generatedData.Put("SnapshotSlug", slug)
 
 	return multistep.ActionContinue
 }
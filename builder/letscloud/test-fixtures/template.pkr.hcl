@@ -1,18 +1,20 @@
 # Copyright (c) HashiCorp, Inc.
 # SPDX-License-Identifier: MPL-2.0
 
-source "letscloud-my-builder" "basic-example" {
-  mock = "mock-config"
+source "letscloud" "basic-example" {
+  location_slug = "mia1"
+  plan_slug     = "1vcpu-1gb-10ssd"
+  image_slug    = "ubuntu-24.04-x86_64"
 }
 
 build {
   sources = [
-    "source.letscloud-my-builder.basic-example"
+    "source.letscloud.basic-example"
   ]
 
   provisioner "shell-local" {
     inline = [
-      "echo build generated data: ${build.GeneratedMockData}",
+      "echo build generated data: ${build.InstanceID} ${build.Location}",
     ]
   }
-}
\ No newline at end of file
+}
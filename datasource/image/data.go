@@ -0,0 +1,236 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput
+
+package image
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/letscloud-community/letscloud-go/domains"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+)
+
+// Config represents the configuration for the LetsCloud image data source.
+type Config struct {
+	letscloud.AccessConfig `mapstructure:",squash"`
+
+	LocationSlug string `mapstructure:"location_slug"`
+	OSFamily     string `mapstructure:"os_family"`     // Optional: e.g. "ubuntu"
+	VersionRegex string `mapstructure:"version_regex"` // Optional: e.g. "^24\\."
+	Architecture string `mapstructure:"architecture"`  // Optional: e.g. "x86_64"
+	MostRecent   bool   `mapstructure:"most_recent"`   // Optional: Defaults to false
+
+	versionRegex *regexp.Regexp
+}
+
+// Datasource looks up a LetsCloud image by filter.
+type Datasource struct {
+	config Config
+}
+
+// DatasourceOutput is the image selected by the data source.
+type DatasourceOutput struct {
+	Slug         string `mapstructure:"slug"`
+	Distro       string `mapstructure:"distro"`
+	OS           string `mapstructure:"os"`
+	Family       string `mapstructure:"family"`
+	Version      string `mapstructure:"version"`
+	Architecture string `mapstructure:"architecture"`
+}
+
+// imageInfo is an image with the family, version and architecture parsed
+// out of its slug, e.g. "ubuntu-24.04-x86_64".
+type imageInfo struct {
+	domains.Image
+	Family       string
+	Version      string
+	Architecture string
+}
+
+// architectureAliases maps the spellings found in slugs to a canonical name.
+var architectureAliases = map[string]string{
+	"x86_64":  "x86_64",
+	"amd64":   "x86_64",
+	"x64":     "x86_64",
+	"arm64":   "arm64",
+	"aarch64": "arm64",
+	"i386":    "i386",
+	"i686":    "i386",
+	"x86":     "i386",
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+
+	if es := d.config.AccessConfig.Prepare(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if d.config.LocationSlug == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`location_slug` is required"))
+	}
+
+	if d.config.VersionRegex != "" {
+		d.config.versionRegex, err = regexp.Compile(d.config.VersionRegex)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid `version_regex`: %s", err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	sdkClient, err := d.config.Client()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	images, err := sdkClient.LocationImages(d.config.LocationSlug)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("unable to list images in %s: %s", d.config.LocationSlug, err)
+	}
+
+	image, err := d.config.selectImage(images)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	output := DatasourceOutput{
+		Slug:         image.Slug,
+		Distro:       image.Distro,
+		OS:           image.OS,
+		Family:       image.Family,
+		Version:      image.Version,
+		Architecture: image.Architecture,
+	}
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+// selectImage returns the single image matching the filters, or the one with
+// the highest version when most_recent is set.
+func (c *Config) selectImage(images []domains.Image) (*imageInfo, error) {
+	var matches []imageInfo
+	for _, image := range images {
+		info := parseImage(image)
+		if c.matches(info) {
+			matches = append(matches, info)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no image in %s matches the given filters", c.LocationSlug)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if cmp := compareVersions(matches[i].Version, matches[j].Version); cmp != 0 {
+			return cmp > 0
+		}
+		return matches[i].Slug < matches[j].Slug
+	})
+
+	if len(matches) > 1 && !c.MostRecent {
+		slugs := make([]string, len(matches))
+		for i, m := range matches {
+			slugs[i] = m.Slug
+		}
+		return nil, fmt.Errorf("%d images match the given filters (%s); "+
+			"narrow the filters or set `most_recent = true`", len(matches), strings.Join(slugs, ", "))
+	}
+
+	return &matches[0], nil
+}
+
+func (c *Config) matches(info imageInfo) bool {
+	if c.OSFamily != "" &&
+		!strings.EqualFold(c.OSFamily, info.Family) &&
+		!strings.EqualFold(c.OSFamily, info.Distro) {
+		return false
+	}
+	if c.versionRegex != nil && !c.versionRegex.MatchString(info.Version) {
+		return false
+	}
+	if c.Architecture != "" && normalizeArchitecture(c.Architecture) != info.Architecture {
+		return false
+	}
+	return true
+}
+
+// parseImage splits a slug such as "ubuntu-24.04-x86_64" into its family,
+// version and architecture.
+func parseImage(image domains.Image) imageInfo {
+	info := imageInfo{Image: image}
+
+	parts := strings.Split(image.Slug, "-")
+	if len(parts) > 1 {
+		if arch, ok := architectureAliases[strings.ToLower(parts[len(parts)-1])]; ok {
+			info.Architecture = arch
+			parts = parts[:len(parts)-1]
+		}
+	}
+
+	info.Family = strings.ToLower(parts[0])
+	info.Version = strings.Join(parts[1:], "-")
+	return info
+}
+
+func normalizeArchitecture(arch string) string {
+	arch = strings.ToLower(arch)
+	if canonical, ok := architectureAliases[arch]; ok {
+		return canonical
+	}
+	return arch
+}
+
+var versionNumber = regexp.MustCompile(`\d+`)
+
+// compareVersions compares the numeric components of two versions, so that
+// "24.04" sorts after "22.10" and "9" before "12".
+func compareVersions(a, b string) int {
+	an := versionNumber.FindAllString(a, -1)
+	bn := versionNumber.FindAllString(b, -1)
+
+	for i := 0; i < len(an) && i < len(bn); i++ {
+		x, _ := strconv.Atoi(an[i])
+		y, _ := strconv.Atoi(bn[i])
+		if x != y {
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+
+	switch {
+	case len(an) > len(bn):
+		return 1
+	case len(an) < len(bn):
+		return -1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,80 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package image
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	APIKey          *string `mapstructure:"api_key" cty:"api_key" hcl:"api_key"`
+	APIURL          *string `mapstructure:"api_url" cty:"api_url" hcl:"api_url"`
+	Profile         *string `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile *string `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
+	LocationSlug    *string `mapstructure:"location_slug" cty:"location_slug" hcl:"location_slug"`
+	OSFamily        *string `mapstructure:"os_family" cty:"os_family" hcl:"os_family"`
+	VersionRegex    *string `mapstructure:"version_regex" cty:"version_regex" hcl:"version_regex"`
+	Architecture    *string `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+	MostRecent      *bool   `mapstructure:"most_recent" cty:"most_recent" hcl:"most_recent"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"api_key":          &hcldec.AttrSpec{Name: "api_key", Type: cty.String, Required: false},
+		"api_url":          &hcldec.AttrSpec{Name: "api_url", Type: cty.String, Required: false},
+		"profile":          &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file": &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
+		"location_slug":    &hcldec.AttrSpec{Name: "location_slug", Type: cty.String, Required: false},
+		"os_family":        &hcldec.AttrSpec{Name: "os_family", Type: cty.String, Required: false},
+		"version_regex":    &hcldec.AttrSpec{Name: "version_regex", Type: cty.String, Required: false},
+		"architecture":     &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+		"most_recent":      &hcldec.AttrSpec{Name: "most_recent", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	Slug         *string `mapstructure:"slug" cty:"slug" hcl:"slug"`
+	Distro       *string `mapstructure:"distro" cty:"distro" hcl:"distro"`
+	OS           *string `mapstructure:"os" cty:"os" hcl:"os"`
+	Family       *string `mapstructure:"family" cty:"family" hcl:"family"`
+	Version      *string `mapstructure:"version" cty:"version" hcl:"version"`
+	Architecture *string `mapstructure:"architecture" cty:"architecture" hcl:"architecture"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"slug":         &hcldec.AttrSpec{Name: "slug", Type: cty.String, Required: false},
+		"distro":       &hcldec.AttrSpec{Name: "distro", Type: cty.String, Required: false},
+		"os":           &hcldec.AttrSpec{Name: "os", Type: cty.String, Required: false},
+		"family":       &hcldec.AttrSpec{Name: "family", Type: cty.String, Required: false},
+		"version":      &hcldec.AttrSpec{Name: "version", Type: cty.String, Required: false},
+		"architecture": &hcldec.AttrSpec{Name: "architecture", Type: cty.String, Required: false},
+	}
+	return s
+}
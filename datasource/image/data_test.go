@@ -0,0 +1,128 @@
+package image
+
+import (
+	"testing"
+
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+)
+
+func testImages() []domains.Image {
+	return []domains.Image{
+		{Slug: "ubuntu-20.04-x86_64", Distro: "Ubuntu", OS: "linux"},
+		{Slug: "ubuntu-22.04-x86_64", Distro: "Ubuntu", OS: "linux"},
+		{Slug: "ubuntu-24.04-x86_64", Distro: "Ubuntu", OS: "linux"},
+		{Slug: "ubuntu-24.04-arm64", Distro: "Ubuntu", OS: "linux"},
+		{Slug: "debian-9-x86_64", Distro: "Debian", OS: "linux"},
+		{Slug: "debian-12-x86_64", Distro: "Debian", OS: "linux"},
+		{Slug: "windows-2022", Distro: "Windows Server", OS: "windows"},
+	}
+}
+
+func TestDatasource(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.AddImages("mia1", testImages()...)
+
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "exact version",
+			raw:  map[string]interface{}{"os_family": "ubuntu", "version_regex": `^22\.`},
+			want: "ubuntu-22.04-x86_64",
+		},
+		{
+			name: "most recent with architecture",
+			raw:  map[string]interface{}{"os_family": "Ubuntu", "architecture": "amd64", "most_recent": true},
+			want: "ubuntu-24.04-x86_64",
+		},
+		{
+			name: "architecture alias",
+			raw:  map[string]interface{}{"os_family": "ubuntu", "architecture": "aarch64"},
+			want: "ubuntu-24.04-arm64",
+		},
+		{
+			name: "numeric version ordering",
+			raw:  map[string]interface{}{"os_family": "debian", "most_recent": true},
+			want: "debian-12-x86_64",
+		},
+		{
+			name: "family matched on distro",
+			raw:  map[string]interface{}{"os_family": "windows server"},
+			want: "windows-2022",
+		},
+		{
+			name:    "ambiguous without most_recent",
+			raw:     map[string]interface{}{"os_family": "ubuntu"},
+			wantErr: true,
+		},
+		{
+			name:    "no match",
+			raw:     map[string]interface{}{"os_family": "centos"},
+			wantErr: true,
+		},
+		{
+			name:    "unknown location",
+			raw:     map[string]interface{}{"location_slug": "nowhere", "most_recent": true},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"api_key":       fakeapi.DefaultAPIKey,
+				"api_url":       server.URL,
+				"location_slug": "mia1",
+			}
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+
+			var d Datasource
+			if err := d.Configure(raw); err != nil {
+				t.Fatalf("unexpected configure error: %s", err)
+			}
+
+			out, err := d.Execute()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := out.GetAttr("slug").AsString(); got != tc.want {
+				t.Errorf("expected slug %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDatasourceConfigure(t *testing.T) {
+	cases := []struct {
+		name string
+		raw  map[string]interface{}
+	}{
+		{name: "missing location", raw: map[string]interface{}{}},
+		{name: "invalid regex", raw: map[string]interface{}{"location_slug": "mia1", "version_regex": "("}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.raw["api_key"] = fakeapi.DefaultAPIKey
+
+			var d Datasource
+			if err := d.Configure(tc.raw); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput
+
+package plan
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/letscloud-community/letscloud-go/domains"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+)
+
+// Config represents the configuration for the LetsCloud plan data source.
+type Config struct {
+	letscloud.AccessConfig `mapstructure:",squash"`
+
+	MinCPUs      int    `mapstructure:"min_cpus"`      // Optional: Defaults to 0
+	MinMemory    int    `mapstructure:"min_memory"`    // Optional: In MB, defaults to 0
+	MinDisk      int    `mapstructure:"min_disk"`      // Optional: In GB, defaults to 0
+	LocationSlug string `mapstructure:"location_slug"` // Optional: Defaults to every available location
+}
+
+// Datasource resolves the cheapest LetsCloud plan meeting resource
+// requirements.
+type Datasource struct {
+	config Config
+}
+
+// DatasourceOutput is the plan selected by the data source.
+type DatasourceOutput struct {
+	Slug         string  `mapstructure:"slug"`
+	LocationSlug string  `mapstructure:"location_slug"`
+	CPUs         int     `mapstructure:"cpus"`
+	Memory       int     `mapstructure:"memory"`
+	Disk         int     `mapstructure:"disk"`
+	Bandwidth    int     `mapstructure:"bandwidth"`
+	MonthlyPrice float64 `mapstructure:"monthly_price"`
+	Currency     string  `mapstructure:"currency"`
+}
+
+// candidate is a plan offered in a specific location.
+type candidate struct {
+	domains.Plan
+	LocationSlug string
+	Price        float64
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+
+	if es := d.config.AccessConfig.Prepare(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	minimums := map[string]int{
+		"min_cpus":   d.config.MinCPUs,
+		"min_memory": d.config.MinMemory,
+		"min_disk":   d.config.MinDisk,
+	}
+	for field, value := range minimums {
+		if value < 0 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("`%s` must not be negative", field))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	sdkClient, err := d.config.Client()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	locations := []string{d.config.LocationSlug}
+	if d.config.LocationSlug == "" {
+		all, err := sdkClient.Locations()
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("unable to list locations: %s", err)
+		}
+		locations = locations[:0]
+		for _, loc := range all {
+			if loc.Available {
+				locations = append(locations, loc.Slug)
+			}
+		}
+	}
+
+	var candidates []candidate
+	for _, slug := range locations {
+		plans, err := sdkClient.LocationPlans(slug)
+		if err != nil {
+			return cty.NullVal(cty.EmptyObject), fmt.Errorf("unable to list plans in %s: %s", slug, err)
+		}
+		for _, plan := range plans {
+			price, err := parsePrice(plan.MonthlyValue)
+			if err != nil {
+				log.Printf("[WARN] ignoring plan %s in %s: %s", plan.Slug, slug, err)
+				continue
+			}
+			candidates = append(candidates, candidate{Plan: plan, LocationSlug: slug, Price: price})
+		}
+	}
+
+	plan, err := d.config.selectPlan(candidates)
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	output := DatasourceOutput{
+		Slug:         plan.Slug,
+		LocationSlug: plan.LocationSlug,
+		CPUs:         plan.Core,
+		Memory:       plan.Memory,
+		Disk:         plan.Disk,
+		Bandwidth:    plan.Bandwidth,
+		MonthlyPrice: plan.Price,
+		Currency:     plan.CurrencyCode,
+	}
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+// selectPlan returns the cheapest candidate meeting the minimums. Ties are
+// broken by the smallest plan, then by slug, to keep the result stable.
+func (c *Config) selectPlan(candidates []candidate) (*candidate, error) {
+	var matches []candidate
+	for _, p := range candidates {
+		if p.Core >= c.MinCPUs && p.Memory >= c.MinMemory && p.Disk >= c.MinDisk {
+			matches = append(matches, p)
+		}
+	}
+
+	if len(matches) == 0 {
+		where := "any available location"
+		if c.LocationSlug != "" {
+			where = c.LocationSlug
+		}
+		return nil, fmt.Errorf("no plan in %s has at least %d vCPUs, %d MB memory and %d GB disk",
+			where, c.MinCPUs, c.MinMemory, c.MinDisk)
+	}
+
+	// Prices in different currencies cannot be compared.
+	var currencies []string
+	for _, p := range matches {
+		if !slices.Contains(currencies, p.CurrencyCode) {
+			currencies = append(currencies, p.CurrencyCode)
+		}
+	}
+	if len(currencies) > 1 {
+		slices.Sort(currencies)
+		return nil, fmt.Errorf("the matching plans are priced in different currencies (%s): set `location_slug` to compare the plans of one location",
+			strings.Join(currencies, ", "))
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch {
+		case a.Price != b.Price:
+			return a.Price < b.Price
+		case a.Core != b.Core:
+			return a.Core < b.Core
+		case a.Memory != b.Memory:
+			return a.Memory < b.Memory
+		case a.Disk != b.Disk:
+			return a.Disk < b.Disk
+		case a.Slug != b.Slug:
+			return a.Slug < b.Slug
+		}
+		return a.LocationSlug < b.LocationSlug
+	})
+
+	return &matches[0], nil
+}
+
+// priceFormat matches a monthly value such as "5.00", "5,00", "1,234.00" or
+// "1.234,00": an integer part, with or without thousands separators, and
+// at most two decimals.
+var priceFormat = regexp.MustCompile(`^(\d+|\d{1,3}(?:[.,]\d{3})+)(?:[.,](\d{1,2}))?$`)
+
+// parsePrice parses a monthly value in any of the formats of priceFormat.
+func parsePrice(value string) (float64, error) {
+	m := priceFormat.FindStringSubmatch(strings.TrimSpace(value))
+	if m == nil {
+		return 0, fmt.Errorf("invalid monthly value %q", value)
+	}
+	number := strings.NewReplacer(",", "", ".", "").Replace(m[1])
+	if m[2] != "" {
+		number += "." + m[2]
+	}
+	return strconv.ParseFloat(number, 64)
+}
@@ -0,0 +1,82 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package plan
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	APIKey          *string `mapstructure:"api_key" cty:"api_key" hcl:"api_key"`
+	APIURL          *string `mapstructure:"api_url" cty:"api_url" hcl:"api_url"`
+	Profile         *string `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile *string `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
+	MinCPUs         *int    `mapstructure:"min_cpus" cty:"min_cpus" hcl:"min_cpus"`
+	MinMemory       *int    `mapstructure:"min_memory" cty:"min_memory" hcl:"min_memory"`
+	MinDisk         *int    `mapstructure:"min_disk" cty:"min_disk" hcl:"min_disk"`
+	LocationSlug    *string `mapstructure:"location_slug" cty:"location_slug" hcl:"location_slug"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"api_key":          &hcldec.AttrSpec{Name: "api_key", Type: cty.String, Required: false},
+		"api_url":          &hcldec.AttrSpec{Name: "api_url", Type: cty.String, Required: false},
+		"profile":          &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file": &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
+		"min_cpus":         &hcldec.AttrSpec{Name: "min_cpus", Type: cty.Number, Required: false},
+		"min_memory":       &hcldec.AttrSpec{Name: "min_memory", Type: cty.Number, Required: false},
+		"min_disk":         &hcldec.AttrSpec{Name: "min_disk", Type: cty.Number, Required: false},
+		"location_slug":    &hcldec.AttrSpec{Name: "location_slug", Type: cty.String, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	Slug         *string  `mapstructure:"slug" cty:"slug" hcl:"slug"`
+	LocationSlug *string  `mapstructure:"location_slug" cty:"location_slug" hcl:"location_slug"`
+	CPUs         *int     `mapstructure:"cpus" cty:"cpus" hcl:"cpus"`
+	Memory       *int     `mapstructure:"memory" cty:"memory" hcl:"memory"`
+	Disk         *int     `mapstructure:"disk" cty:"disk" hcl:"disk"`
+	Bandwidth    *int     `mapstructure:"bandwidth" cty:"bandwidth" hcl:"bandwidth"`
+	MonthlyPrice *float64 `mapstructure:"monthly_price" cty:"monthly_price" hcl:"monthly_price"`
+	Currency     *string  `mapstructure:"currency" cty:"currency" hcl:"currency"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"slug":          &hcldec.AttrSpec{Name: "slug", Type: cty.String, Required: false},
+		"location_slug": &hcldec.AttrSpec{Name: "location_slug", Type: cty.String, Required: false},
+		"cpus":          &hcldec.AttrSpec{Name: "cpus", Type: cty.Number, Required: false},
+		"memory":        &hcldec.AttrSpec{Name: "memory", Type: cty.Number, Required: false},
+		"disk":          &hcldec.AttrSpec{Name: "disk", Type: cty.Number, Required: false},
+		"bandwidth":     &hcldec.AttrSpec{Name: "bandwidth", Type: cty.Number, Required: false},
+		"monthly_price": &hcldec.AttrSpec{Name: "monthly_price", Type: cty.Number, Required: false},
+		"currency":      &hcldec.AttrSpec{Name: "currency", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,179 @@
+package plan
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+)
+
+func testServer() *fakeapi.Server {
+	server := fakeapi.NewServer()
+	server.AddPlans("mia1",
+		domains.Plan{Slug: "1vcpu-1gb-10ssd", Core: 1, Memory: 1024, Disk: 10, MonthlyValue: "5.00", CurrencyCode: "USD"},
+		domains.Plan{Slug: "2vcpu-2gb-20ssd", Core: 2, Memory: 2048, Disk: 20, MonthlyValue: "10.00", CurrencyCode: "USD"},
+		domains.Plan{Slug: "2vcpu-4gb-40ssd", Core: 2, Memory: 4096, Disk: 40, MonthlyValue: "20.00", CurrencyCode: "USD"},
+	)
+	server.AddPlans("gru1",
+		domains.Plan{Slug: "2vcpu-4gb-40ssd", Core: 2, Memory: 4096, Disk: 40, MonthlyValue: "18,50", CurrencyCode: "USD"},
+		domains.Plan{Slug: "broken", Core: 8, Memory: 8192, Disk: 80, MonthlyValue: "n/a"},
+	)
+	server.AddPlans("ams1",
+		domains.Plan{Slug: "2vcpu-4gb-40ssd", Core: 2, Memory: 4096, Disk: 40, MonthlyValue: "1.00", CurrencyCode: "USD"},
+	)
+	server.SetAvailable("ams1", false)
+	return server
+}
+
+func TestDatasource(t *testing.T) {
+	server := testServer()
+	defer server.Close()
+
+	cases := []struct {
+		name         string
+		raw          map[string]interface{}
+		want         string
+		wantLocation string
+		wantPrice    float64
+		wantErr      bool
+	}{
+		{
+			name:         "smallest in location",
+			raw:          map[string]interface{}{"location_slug": "mia1"},
+			want:         "1vcpu-1gb-10ssd",
+			wantLocation: "mia1",
+			wantPrice:    5,
+		},
+		{
+			name:         "minimums in location",
+			raw:          map[string]interface{}{"location_slug": "mia1", "min_cpus": 2, "min_memory": 3000},
+			want:         "2vcpu-4gb-40ssd",
+			wantLocation: "mia1",
+			wantPrice:    20,
+		},
+		{
+			name:         "cheapest across available locations",
+			raw:          map[string]interface{}{"min_memory": 4096},
+			want:         "2vcpu-4gb-40ssd",
+			wantLocation: "gru1",
+			wantPrice:    18.5,
+		},
+		{
+			name:    "nothing large enough",
+			raw:     map[string]interface{}{"min_cpus": 8},
+			wantErr: true,
+		},
+		{
+			name:    "unknown location",
+			raw:     map[string]interface{}{"location_slug": "nowhere"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"api_key": fakeapi.DefaultAPIKey,
+				"api_url": server.URL,
+			}
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+
+			var d Datasource
+			if err := d.Configure(raw); err != nil {
+				t.Fatalf("unexpected configure error: %s", err)
+			}
+
+			out, err := d.Execute()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := out.GetAttr("slug").AsString(); got != tc.want {
+				t.Errorf("expected slug %q, got %q", tc.want, got)
+			}
+			if got := out.GetAttr("location_slug").AsString(); got != tc.wantLocation {
+				t.Errorf("expected location %q, got %q", tc.wantLocation, got)
+			}
+			if got, _ := out.GetAttr("monthly_price").AsBigFloat().Float64(); got != tc.wantPrice {
+				t.Errorf("expected price %v, got %v", tc.wantPrice, got)
+			}
+		})
+	}
+}
+
+func TestDatasourceConfigure_negative(t *testing.T) {
+	var d Datasource
+	err := d.Configure(map[string]interface{}{
+		"api_key":  fakeapi.DefaultAPIKey,
+		"min_disk": -1,
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestConfig_selectPlanCurrencies(t *testing.T) {
+	candidates := []candidate{
+		{Plan: domains.Plan{Slug: "small", Core: 1, CurrencyCode: "USD"}, LocationSlug: "mia1", Price: 5},
+		{Plan: domains.Plan{Slug: "small", Core: 1, CurrencyCode: "BRL"}, LocationSlug: "gru1", Price: 25},
+		{Plan: domains.Plan{Slug: "large", Core: 4, CurrencyCode: "BRL"}, LocationSlug: "gru1", Price: 90},
+	}
+
+	c := &Config{}
+	if _, err := c.selectPlan(candidates); err == nil || !strings.Contains(err.Error(), "BRL, USD") {
+		t.Errorf("expected an error naming both currencies, got %v", err)
+	}
+
+	// Only the plans in one currency meet the minimums.
+	c = &Config{MinCPUs: 2}
+	plan, err := c.selectPlan(candidates)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if plan.Slug != "large" {
+		t.Errorf("expected plan large, got %s", plan.Slug)
+	}
+}
+
+func TestParsePrice(t *testing.T) {
+	cases := []struct {
+		value   string
+		want    float64
+		wantErr bool
+	}{
+		{value: "5.00", want: 5},
+		{value: "18,50", want: 18.5},
+		{value: " 7.5 ", want: 7.5},
+		{value: "12", want: 12},
+		{value: "1,234.00", want: 1234},
+		{value: "1.234,56", want: 1234.56},
+		{value: "1,234,567.8", want: 1234567.8},
+		{value: "1,234", want: 1234},
+		{value: "n/a", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "1e3", wantErr: true},
+		{value: "12,34,56", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		got, err := parsePrice(tc.value)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("parsePrice(%q): expected an error, got %v", tc.value, got)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("parsePrice(%q) = %v, %v; want %v", tc.value, got, err, tc.want)
+		}
+	}
+}
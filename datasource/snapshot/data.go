@@ -0,0 +1,193 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config,DatasourceOutput
+
+package snapshot
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/hcl2helper"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/letscloud-community/letscloud-go/domains"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+)
+
+// Config represents the configuration for the LetsCloud snapshot data source.
+type Config struct {
+	letscloud.AccessConfig `mapstructure:",squash"`
+
+	NameRegex    string `mapstructure:"name_regex"`    // Optional: Regular expression the label must match
+	LabelPrefix  string `mapstructure:"label_prefix"`  // Optional: Prefix the label must start with
+	LocationSlug string `mapstructure:"location_slug"` // Optional: Location the snapshot must be available in
+	MaxAge       string `mapstructure:"max_age"`       // Optional: e.g. "168h"
+	MostRecent   bool   `mapstructure:"most_recent"`   // Optional: Defaults to false
+
+	nameRegex *regexp.Regexp
+	maxAge    time.Duration
+}
+
+// Datasource finds an existing LetsCloud snapshot.
+type Datasource struct {
+	config Config
+	now    func() time.Time
+}
+
+// DatasourceOutput is the snapshot selected by the data source.
+type DatasourceOutput struct {
+	Slug         string   `mapstructure:"slug"`
+	Label        string   `mapstructure:"label"`
+	Size         int      `mapstructure:"size"`
+	OSReference  string   `mapstructure:"os_reference"`
+	Locations    []string `mapstructure:"locations"`
+	CreationTime string   `mapstructure:"creation_time"`
+}
+
+// match is a snapshot with the creation time parsed from its label.
+type match struct {
+	domains.Snapshot
+	Created    time.Time
+	HasCreated bool
+}
+
+func (d *Datasource) ConfigSpec() hcldec.ObjectSpec {
+	return d.config.FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Configure(raws ...interface{}) error {
+	err := config.Decode(&d.config, nil, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+
+	if es := d.config.AccessConfig.Prepare(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if d.config.NameRegex != "" {
+		d.config.nameRegex, err = regexp.Compile(d.config.NameRegex)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid `name_regex`: %s", err))
+		}
+	}
+
+	if d.config.MaxAge != "" {
+		d.config.maxAge, err = time.ParseDuration(d.config.MaxAge)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid format for `max_age`: %s", err))
+		} else if d.config.maxAge <= 0 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("`max_age` must be positive"))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (d *Datasource) OutputSpec() hcldec.ObjectSpec {
+	return (&DatasourceOutput{}).FlatMapstructure().HCL2Spec()
+}
+
+func (d *Datasource) Execute() (cty.Value, error) {
+	sdkClient, err := d.config.Client()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	snapshots, err := sdkClient.Snapshots()
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), fmt.Errorf("unable to list snapshots: %s", err)
+	}
+
+	now := time.Now
+	if d.now != nil {
+		now = d.now
+	}
+
+	snap, err := d.config.selectSnapshot(snapshots, now())
+	if err != nil {
+		return cty.NullVal(cty.EmptyObject), err
+	}
+
+	output := DatasourceOutput{
+		Slug:        snap.Slug,
+		Label:       snap.Label,
+		Size:        snap.Size,
+		OSReference: snap.OsReference,
+		Locations:   snap.Locations,
+	}
+	if snap.HasCreated {
+		output.CreationTime = snap.Created.UTC().Format(time.RFC3339)
+	}
+	return hcl2helper.HCL2ValueFromConfig(output, d.OutputSpec()), nil
+}
+
+// selectSnapshot returns the single finished snapshot matching the filters,
+// or the newest one when most_recent is set.
+func (c *Config) selectSnapshot(snapshots []domains.Snapshot, now time.Time) (*match, error) {
+	var matches []match
+	for _, snap := range snapshots {
+		m := match{Snapshot: snap}
+		m.Created, m.HasCreated = letscloud.SnapshotTime(snap.Label)
+		if c.matches(m, now) {
+			matches = append(matches, m)
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no finished snapshot matches the given filters")
+	}
+
+	// Newest first; snapshots without a timestamp in their label sort last.
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.HasCreated != b.HasCreated {
+			return a.HasCreated
+		}
+		if !a.Created.Equal(b.Created) {
+			return a.Created.After(b.Created)
+		}
+		return a.Slug < b.Slug
+	})
+
+	if len(matches) > 1 && !c.MostRecent {
+		labels := make([]string, len(matches))
+		for i, m := range matches {
+			labels[i] = m.Label
+		}
+		return nil, fmt.Errorf("%d snapshots match the given filters (%s); "+
+			"narrow the filters or set `most_recent = true`", len(matches), strings.Join(labels, ", "))
+	}
+
+	return &matches[0], nil
+}
+
+func (c *Config) matches(m match, now time.Time) bool {
+	if !m.Build {
+		return false
+	}
+	if c.LabelPrefix != "" && !strings.HasPrefix(m.Label, c.LabelPrefix) {
+		return false
+	}
+	if c.nameRegex != nil && !c.nameRegex.MatchString(m.Label) {
+		return false
+	}
+	if c.LocationSlug != "" && !slices.Contains(m.Locations, c.LocationSlug) {
+		return false
+	}
+	if c.maxAge > 0 && (!m.HasCreated || now.Sub(m.Created) > c.maxAge) {
+		return false
+	}
+	return true
+}
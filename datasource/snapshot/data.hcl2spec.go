@@ -0,0 +1,80 @@
+// Code generated by "packer-sdc mapstructure-to-hcl2"; DO NOT EDIT.
+
+package snapshot
+
+import (
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatConfig struct {
+	APIKey          *string `mapstructure:"api_key" cty:"api_key" hcl:"api_key"`
+	APIURL          *string `mapstructure:"api_url" cty:"api_url" hcl:"api_url"`
+	Profile         *string `mapstructure:"profile" cty:"profile" hcl:"profile"`
+	CredentialsFile *string `mapstructure:"credentials_file" cty:"credentials_file" hcl:"credentials_file"`
+	NameRegex       *string `mapstructure:"name_regex" cty:"name_regex" hcl:"name_regex"`
+	LabelPrefix     *string `mapstructure:"label_prefix" cty:"label_prefix" hcl:"label_prefix"`
+	LocationSlug    *string `mapstructure:"location_slug" cty:"location_slug" hcl:"location_slug"`
+	MaxAge          *string `mapstructure:"max_age" cty:"max_age" hcl:"max_age"`
+	MostRecent      *bool   `mapstructure:"most_recent" cty:"most_recent" hcl:"most_recent"`
+}
+
+// FlatMapstructure returns a new FlatConfig.
+// FlatConfig is an auto-generated flat version of Config.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*Config) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatConfig)
+}
+
+// HCL2Spec returns the hcl spec of a Config.
+// This spec is used by HCL to read the fields of Config.
+// The decoded values from this spec will then be applied to a FlatConfig.
+func (*FlatConfig) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"api_key":          &hcldec.AttrSpec{Name: "api_key", Type: cty.String, Required: false},
+		"api_url":          &hcldec.AttrSpec{Name: "api_url", Type: cty.String, Required: false},
+		"profile":          &hcldec.AttrSpec{Name: "profile", Type: cty.String, Required: false},
+		"credentials_file": &hcldec.AttrSpec{Name: "credentials_file", Type: cty.String, Required: false},
+		"name_regex":       &hcldec.AttrSpec{Name: "name_regex", Type: cty.String, Required: false},
+		"label_prefix":     &hcldec.AttrSpec{Name: "label_prefix", Type: cty.String, Required: false},
+		"location_slug":    &hcldec.AttrSpec{Name: "location_slug", Type: cty.String, Required: false},
+		"max_age":          &hcldec.AttrSpec{Name: "max_age", Type: cty.String, Required: false},
+		"most_recent":      &hcldec.AttrSpec{Name: "most_recent", Type: cty.Bool, Required: false},
+	}
+	return s
+}
+
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents of a field with a `mapstructure:,squash` tag are bubbled up.
+type FlatDatasourceOutput struct {
+	Slug         *string  `mapstructure:"slug" cty:"slug" hcl:"slug"`
+	Label        *string  `mapstructure:"label" cty:"label" hcl:"label"`
+	Size         *int     `mapstructure:"size" cty:"size" hcl:"size"`
+	OSReference  *string  `mapstructure:"os_reference" cty:"os_reference" hcl:"os_reference"`
+	Locations    []string `mapstructure:"locations" cty:"locations" hcl:"locations"`
+	CreationTime *string  `mapstructure:"creation_time" cty:"creation_time" hcl:"creation_time"`
+}
+
+// FlatMapstructure returns a new FlatDatasourceOutput.
+// FlatDatasourceOutput is an auto-generated flat version of DatasourceOutput.
+// Where the contents a fields with a `mapstructure:,squash` tag are bubbled up.
+func (*DatasourceOutput) FlatMapstructure() interface{ HCL2Spec() map[string]hcldec.Spec } {
+	return new(FlatDatasourceOutput)
+}
+
+// HCL2Spec returns the hcl spec of a DatasourceOutput.
+// This spec is used by HCL to read the fields of DatasourceOutput.
+// The decoded values from this spec will then be applied to a FlatDatasourceOutput.
+func (*FlatDatasourceOutput) HCL2Spec() map[string]hcldec.Spec {
+	s := map[string]hcldec.Spec{
+		"slug":          &hcldec.AttrSpec{Name: "slug", Type: cty.String, Required: false},
+		"label":         &hcldec.AttrSpec{Name: "label", Type: cty.String, Required: false},
+		"size":          &hcldec.AttrSpec{Name: "size", Type: cty.Number, Required: false},
+		"os_reference":  &hcldec.AttrSpec{Name: "os_reference", Type: cty.String, Required: false},
+		"locations":     &hcldec.AttrSpec{Name: "locations", Type: cty.List(cty.String), Required: false},
+		"creation_time": &hcldec.AttrSpec{Name: "creation_time", Type: cty.String, Required: false},
+	}
+	return s
+}
@@ -0,0 +1,124 @@
+package snapshot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+)
+
+func TestDatasource(t *testing.T) {
+	now := time.Unix(1718000000, 0)
+	day := int64(24 * 60 * 60)
+	label := func(prefix string, daysAgo int64) string {
+		return fmt.Sprintf("%s-%d", prefix, now.Unix()-daysAgo*day)
+	}
+
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.AddSnapshot(domains.Snapshot{Slug: "base-old", Label: label("base", 10), Locations: []string{"mia1"}})
+	server.AddSnapshot(domains.Snapshot{Slug: "base-new", Label: label("base", 1), Locations: []string{"mia1"}})
+	server.AddSnapshot(domains.Snapshot{Slug: "hardened", Label: label("hardened", 2), Locations: []string{"gru1"}})
+	server.AddSnapshot(domains.Snapshot{Slug: "manual", Label: "base-manual", Locations: []string{"mia1"}})
+
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "newest by prefix",
+			raw:  map[string]interface{}{"label_prefix": "base-", "most_recent": true},
+			want: "base-new",
+		},
+		{
+			name: "name regex",
+			raw:  map[string]interface{}{"name_regex": "^hardened-"},
+			want: "hardened",
+		},
+		{
+			name: "location",
+			raw:  map[string]interface{}{"location_slug": "gru1"},
+			want: "hardened",
+		},
+		{
+			name: "untimestamped labels sort last",
+			raw:  map[string]interface{}{"name_regex": fmt.Sprintf("^base-(manual|%d)$", now.Unix()-10*day), "most_recent": true},
+			want: "base-old",
+		},
+		{
+			name:    "max age",
+			raw:     map[string]interface{}{"label_prefix": "base-", "max_age": "12h"},
+			wantErr: true,
+		},
+		{
+			name:    "ambiguous",
+			raw:     map[string]interface{}{"label_prefix": "base-"},
+			wantErr: true,
+		},
+		{
+			name:    "no match",
+			raw:     map[string]interface{}{"label_prefix": "app-"},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{
+				"api_key": fakeapi.DefaultAPIKey,
+				"api_url": server.URL,
+			}
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+
+			d := Datasource{now: func() time.Time { return now }}
+			if err := d.Configure(raw); err != nil {
+				t.Fatalf("unexpected configure error: %s", err)
+			}
+
+			out, err := d.Execute()
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %#v", out)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got := out.GetAttr("slug").AsString(); got != tc.want {
+				t.Errorf("expected slug %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestDatasource_creationTime(t *testing.T) {
+	server := fakeapi.NewServer()
+	defer server.Close()
+	server.AddSnapshot(domains.Snapshot{Slug: "ready", Label: "app-1718000000"})
+
+	var d Datasource
+	err := d.Configure(map[string]interface{}{
+		"api_key":      fakeapi.DefaultAPIKey,
+		"api_url":      server.URL,
+		"label_prefix": "app-",
+	})
+	if err != nil {
+		t.Fatalf("unexpected configure error: %s", err)
+	}
+
+	out, err := d.Execute()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if got := out.GetAttr("creation_time").AsString(); got != "2024-06-10T06:13:20Z" {
+		t.Errorf("unexpected creation_time %q", got)
+	}
+}
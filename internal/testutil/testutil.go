@@ -0,0 +1,55 @@
+// Package testutil holds the scaffolding shared by the builder and
+// post-processor tests.
+package testutil
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+)
+
+// Ui returns a UI that writes everything to the returned buffer.
+func Ui() (packer.Ui, *bytes.Buffer) {
+	out := new(bytes.Buffer)
+	return &packer.BasicUi{
+		Reader:      new(bytes.Buffer),
+		Writer:      out,
+		ErrorWriter: out,
+	}, out
+}
+
+// Server starts a fake API with the given locations available, and closes it
+// when the test ends. Its API key is the test name, so that tests do not
+// share the rate limit of an account.
+func Server(t *testing.T, locations ...string) *fakeapi.Server {
+	server := fakeapi.NewServer()
+	t.Cleanup(server.Close)
+	server.APIKey = t.Name()
+	for _, loc := range locations {
+		server.SetAvailable(loc, true)
+	}
+	return server
+}
+
+// Configure configures p to talk to server, with the settings of each of
+// raws applied in order, and fails the test if that fails.
+func Configure(t *testing.T, p packer.PostProcessor, server *fakeapi.Server, raws ...map[string]interface{}) {
+	t.Helper()
+
+	raw := map[string]interface{}{
+		"api_key": server.APIKey,
+		"api_url": server.URL,
+	}
+	for _, r := range raws {
+		for k, v := range r {
+			raw[k] = v
+		}
+	}
+
+	if err := p.Configure(raw); err != nil {
+		t.Fatalf("unexpected configuration error: %s", err)
+	}
+}
@@ -7,12 +7,20 @@ import (
 	"github.com/hashicorp/packer-plugin-sdk/plugin"
 
 	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+	"github.com/letscloud-community/packer-plugin-letscloud/datasource/image"
+	"github.com/letscloud-community/packer-plugin-letscloud/datasource/plan"
+	"github.com/letscloud-community/packer-plugin-letscloud/datasource/snapshot"
+	"github.com/letscloud-community/packer-plugin-letscloud/post-processor/retention"
 	letscloudVersion "github.com/letscloud-community/packer-plugin-letscloud/version"
 )
 
 func main() {
 	pps := plugin.NewSet()
 	pps.RegisterBuilder(plugin.DEFAULT_NAME, new(letscloud.Builder))
+	pps.RegisterDatasource("image", new(image.Datasource))
+	pps.RegisterDatasource("plan", new(plan.Datasource))
+	pps.RegisterDatasource("snapshot", new(snapshot.Datasource))
+	pps.RegisterPostProcessor("retention", new(retention.PostProcessor))
 	pps.SetVersion(letscloudVersion.PluginVersion)
 	err := pps.Run()
 	if err != nil {
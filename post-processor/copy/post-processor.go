@@ -0,0 +1,145 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+// Package letscloudcopy copies the snapshot of a build to other locations.
+// It is not registered with the plugin until the LetsCloud API documents a
+// snapshot copy call; CopySnapshot guesses at one.
+package letscloudcopy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+)
+
+const defaultCopyTimeout = 30 * time.Minute
+
+// Config represents the configuration for the LetsCloud copy post-processor.
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	letscloud.AccessConfig `mapstructure:",squash"`
+
+	DestinationLocations []string `mapstructure:"destination_locations"`
+	CopyTimeout          string   `mapstructure:"copy_timeout"` // Optional: Defaults to 30m
+
+	copyTimeout time.Duration
+	ctx         interpolate.Context
+}
+
+// PostProcessor copies the snapshot of a LetsCloud build to other locations.
+type PostProcessor struct {
+	config       Config
+	pollInterval time.Duration
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec {
+	return p.config.FlatMapstructure().HCL2Spec()
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "packer.post-processor.letscloud-copy",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+
+	if es := p.config.AccessConfig.Prepare(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if len(p.config.DestinationLocations) == 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`destination_locations` is required"))
+	}
+	for _, loc := range p.config.DestinationLocations {
+		if loc == "" {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("`destination_locations` must not contain empty slugs"))
+			break
+		}
+	}
+
+	if p.config.CopyTimeout == "" {
+		p.config.CopyTimeout = defaultCopyTimeout.String()
+	}
+	p.config.copyTimeout, err = time.ParseDuration(p.config.CopyTimeout)
+	if err != nil {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid format for `copy_timeout`: %s", err))
+	} else if p.config.copyTimeout <= 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`copy_timeout` must be positive"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packer.Ui, source packer.Artifact) (packer.Artifact, bool, bool, error) {
+	if source.BuilderId() != letscloud.BuilderId {
+		return nil, false, false, fmt.Errorf("unknown artifact type %s: can only copy LetsCloud snapshots", source.BuilderId())
+	}
+
+	slug, _ := source.State("snapshot_slug").(string)
+	if slug == "" {
+		return nil, false, false, fmt.Errorf("artifact %s has no snapshot to copy", source.Id())
+	}
+	sourceLocation, _ := source.State("location_slug").(string)
+
+	// Builds with location_slugs already made copies.
+	locations, _ := source.State("location_slugs").([]string)
+	locations = slices.Clone(locations)
+	if len(locations) == 0 {
+		locations = []string{sourceLocation}
+	}
+	var destinations []string
+	for _, loc := range p.config.DestinationLocations {
+		if !slices.Contains(locations, loc) {
+			locations = append(locations, loc)
+			destinations = append(destinations, loc)
+		}
+	}
+
+	interval := letscloud.DefaultPollInterval
+	if p.pollInterval > 0 {
+		interval = p.pollInterval
+	}
+
+	client := p.config.NewAPIClient()
+	if err := letscloud.CopySnapshotAndWait(ctx, ui, client, slug, destinations, interval, p.config.copyTimeout); err != nil {
+		return nil, false, false, err
+	}
+
+	// Copies keep the slug of the snapshot they were made from.
+	slugs := map[string]string{}
+	for _, loc := range locations {
+		slugs[loc] = slug
+	}
+	state := map[string]interface{}{
+		"location_slugs": locations,
+		"snapshot_slugs": slugs,
+	}
+	for _, key := range []string{
+		"instance_identifier", "instance_ip", "password_output_file", "snapshot_slug",
+		"snapshot_name", "location_slug", "source_image", "plan_slug", "generated_data",
+	} {
+		if v := source.State(key); v != nil {
+			state[key] = v
+		}
+	}
+
+	// The copies share the slug of the source snapshot, so the input
+	// artifact must be kept whatever keep_input_artifact says.
+	return letscloud.NewSnapshotArtifact(client, state), true, true, nil
+}
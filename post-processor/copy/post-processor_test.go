@@ -0,0 +1,224 @@
+package letscloudcopy
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	registryimage "github.com/hashicorp/packer-plugin-sdk/packer/registry/image"
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/testutil"
+)
+
+// testServer returns a fake API with a built snapshot "snap-1" in mia1 and
+// the nyc1 and gru1 locations available.
+func testServer(t *testing.T) *fakeapi.Server {
+	server := testutil.Server(t, "mia1", "nyc1", "gru1")
+	server.AddSnapshot(domains.Snapshot{Slug: "snap-1", Label: "golden", Build: true, Locations: []string{"mia1"}})
+	return server
+}
+
+// testSource returns the artifact of a build of "snap-1", named "golden", in
+// mia1.
+func testSource() packer.Artifact {
+	return letscloud.NewArtifact(map[string]interface{}{
+		"snapshot_slug":  "snap-1",
+		"snapshot_name":  "golden",
+		"location_slug":  "mia1",
+		"source_image":   "ubuntu-24.04-x86_64",
+		"plan_slug":      "1vcpu-1gb-10ssd",
+		"generated_data": map[string]interface{}{"SnapshotSlug": "snap-1"},
+	})
+}
+
+func testPostProcessor(t *testing.T, server *fakeapi.Server, overrides map[string]interface{}) *PostProcessor {
+	t.Helper()
+
+	p := &PostProcessor{pollInterval: 5 * time.Millisecond}
+	testutil.Configure(t, p, server, map[string]interface{}{"destination_locations": []string{"nyc1", "gru1"}}, overrides)
+	return p
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessor_Configure(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			raw:  map[string]interface{}{"destination_locations": []string{"nyc1"}},
+		},
+		{
+			name:    "no destinations",
+			raw:     map[string]interface{}{},
+			wantErr: "`destination_locations` is required",
+		},
+		{
+			name:    "empty destination",
+			raw:     map[string]interface{}{"destination_locations": []string{"nyc1", ""}},
+			wantErr: "must not contain empty slugs",
+		},
+		{
+			name:    "invalid timeout",
+			raw:     map[string]interface{}{"destination_locations": []string{"nyc1"}, "copy_timeout": "soon"},
+			wantErr: "invalid format for `copy_timeout`",
+		},
+		{
+			name:    "negative timeout",
+			raw:     map[string]interface{}{"destination_locations": []string{"nyc1"}, "copy_timeout": "-1m"},
+			wantErr: "`copy_timeout` must be positive",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{"api_key": fakeapi.DefaultAPIKey}
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+
+			p := new(PostProcessor)
+			err := p.Configure(raw)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if p.config.copyTimeout != defaultCopyTimeout {
+					t.Errorf("expected the default copy timeout, got %s", p.config.copyTimeout)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	server := testServer(t)
+	server.CopyPolls = 3
+
+	ui, _ := testutil.Ui()
+	p := testPostProcessor(t, server, map[string]interface{}{
+		"destination_locations": []string{"nyc1", "mia1", "gru1", "nyc1"},
+	})
+	artifact, keep, forceOverride, err := p.PostProcess(context.Background(), ui, testSource())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !keep || !forceOverride {
+		t.Errorf("expected the source artifact to be kept, got keep=%t forceOverride=%t", keep, forceOverride)
+	}
+
+	if got := server.Calls("POST /snapshots/{slug}/copy"); got != 2 {
+		t.Errorf("expected one copy per new location, got %d", got)
+	}
+	snaps := server.Snapshots()
+	if len(snaps) != 1 {
+		t.Fatalf("expected a single snapshot, got %d", len(snaps))
+	}
+	for _, loc := range []string{"mia1", "nyc1", "gru1"} {
+		if !slices.Contains(snaps[0].Locations, loc) {
+			t.Errorf("expected the snapshot in %s, got %v", loc, snaps[0].Locations)
+		}
+	}
+
+	if artifact.BuilderId() != letscloud.BuilderId {
+		t.Errorf("unexpected builder ID %q", artifact.BuilderId())
+	}
+	if got, want := artifact.Id(), "mia1:snap-1,nyc1:snap-1,gru1:snap-1"; got != want {
+		t.Errorf("expected ID %q, got %q", want, got)
+	}
+	if got := artifact.State("source_image"); got != "ubuntu-24.04-x86_64" {
+		t.Errorf("expected the source image to be carried over, got %v", got)
+	}
+	if artifact.State("generated_data") == nil {
+		t.Error("expected the generated data to be carried over")
+	}
+	if got, want := artifact.State("location_slugs"), []string{"mia1", "nyc1", "gru1"}; !slices.Equal(got.([]string), want) {
+		t.Errorf("expected locations %v, got %v", want, got)
+	}
+	images, ok := artifact.State(registryimage.ArtifactStateURI).([]*registryimage.Image)
+	if !ok || len(images) != 3 {
+		t.Fatalf("expected one registry image per location, got %#v", artifact.State(registryimage.ArtifactStateURI))
+	}
+	for _, img := range images {
+		if img.ImageID != "snap-1" {
+			t.Errorf("expected the copies to keep the snapshot slug, got %q in %s", img.ImageID, img.ProviderRegion)
+		}
+	}
+}
+
+func TestPostProcessor_PostProcessErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		source  packer.Artifact
+		setup   func(*fakeapi.Server)
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "other builder",
+			source:  &packer.MockArtifact{BuilderIdValue: "packer.other"},
+			wantErr: "can only copy LetsCloud snapshots",
+		},
+		{
+			name:    "no snapshot",
+			source:  letscloud.NewArtifact(map[string]interface{}{"instance_identifier": "inst-1", "location_slug": "mia1"}),
+			wantErr: "has no snapshot to copy",
+		},
+		{
+			name:    "unknown location",
+			raw:     map[string]interface{}{"destination_locations": []string{"nyc1", "ams1"}},
+			wantErr: "to ams1",
+		},
+		{
+			name: "copy rejected",
+			setup: func(s *fakeapi.Server) {
+				s.Fail("POST /snapshots/{slug}/copy", fakeapi.Failure{Status: 403, Message: "copies are disabled"})
+			},
+			wantErr: "copies are disabled",
+		},
+		{
+			name:    "timeout",
+			setup:   func(s *fakeapi.Server) { s.CopyPolls = 1000 },
+			raw:     map[string]interface{}{"copy_timeout": "50ms"},
+			wantErr: "was not copied to nyc1 within 50ms",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testServer(t)
+			if tc.setup != nil {
+				tc.setup(server)
+			}
+			source := tc.source
+			if source == nil {
+				source = testSource()
+			}
+
+			ui, _ := testutil.Ui()
+			p := testPostProcessor(t, server, tc.raw)
+			artifact, _, _, err := p.PostProcess(context.Background(), ui, source)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+			if artifact != nil {
+				t.Errorf("expected no artifact, got %s", artifact.Id())
+			}
+		})
+	}
+}
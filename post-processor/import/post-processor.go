@@ -0,0 +1,185 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+// Package letscloudimport imports local disk images as LetsCloud snapshots.
+// It is not registered with the plugin until the LetsCloud API documents an
+// image import call; ImportSnapshot guesses at one.
+package letscloudimport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+)
+
+const defaultImportTimeout = time.Hour
+
+// formats maps the disk image file extensions to the formats LetsCloud
+// imports.
+var formats = map[string]string{
+	".qcow2": "qcow2",
+	".raw":   "raw",
+	".img":   "raw",
+}
+
+// Config represents the configuration for the LetsCloud import
+// post-processor.
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	letscloud.AccessConfig `mapstructure:",squash"`
+
+	LocationSlug  string `mapstructure:"location_slug"`
+	SnapshotName  string `mapstructure:"snapshot_name"`  // Optional: Defaults to packer-import-{{timestamp}}
+	Format        string `mapstructure:"format"`         // Optional: qcow2 or raw, guessed from the artifact
+	ImportTimeout string `mapstructure:"import_timeout"` // Optional: Defaults to 1h
+
+	importTimeout time.Duration
+	ctx           interpolate.Context
+}
+
+// PostProcessor imports a local disk image into LetsCloud as a snapshot.
+type PostProcessor struct {
+	config       Config
+	pollInterval time.Duration
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec {
+	return p.config.FlatMapstructure().HCL2Spec()
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "packer.post-processor.letscloud-import",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+
+	if es := p.config.AccessConfig.Prepare(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if p.config.LocationSlug == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`location_slug` is required"))
+	}
+
+	if p.config.SnapshotName == "" {
+		p.config.SnapshotName = fmt.Sprintf("packer-import-%d", time.Now().Unix())
+	}
+
+	switch p.config.Format {
+	case "", "qcow2", "raw":
+	default:
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`format` must be qcow2 or raw, got %q", p.config.Format))
+	}
+
+	if p.config.ImportTimeout == "" {
+		p.config.ImportTimeout = defaultImportTimeout.String()
+	}
+	p.config.importTimeout, err = time.ParseDuration(p.config.ImportTimeout)
+	if err != nil {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid format for `import_timeout`: %s", err))
+	} else if p.config.importTimeout <= 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`import_timeout` must be positive"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packer.Ui, source packer.Artifact) (packer.Artifact, bool, bool, error) {
+	path, err := diskImage(source.Files())
+	if err != nil {
+		return nil, false, false, err
+	}
+	format, err := p.config.format(source, path)
+	if err != nil {
+		return nil, false, false, err
+	}
+
+	interval := letscloud.DefaultPollInterval
+	if p.pollInterval > 0 {
+		interval = p.pollInterval
+	}
+
+	client := p.config.NewAPIClient()
+
+	ctx, cancel := context.WithTimeout(ctx, p.config.importTimeout)
+	defer cancel()
+
+	ui.Say(fmt.Sprintf("Uploading %s (%s) to %s as '%s'...", path, format, p.config.LocationSlug, p.config.SnapshotName))
+	snap, err := client.ImportSnapshot(ctx, path, format, p.config.SnapshotName, p.config.LocationSlug)
+	if err != nil {
+		return nil, false, false, fmt.Errorf("failed to import %s: %s", path, err)
+	}
+
+	if err := letscloud.WaitForSnapshot(ctx, ui, client, snap.Slug, interval, p.config.importTimeout); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("import did not finish within the import timeout (%s)", p.config.importTimeout)
+		}
+		// Do not leave a half imported snapshot behind.
+		if delErr := client.DeleteSnapshot(context.Background(), snap.Slug); delErr != nil {
+			ui.Error(fmt.Sprintf("Error deleting snapshot %s: %s", snap.Slug, delErr))
+		}
+		return nil, false, false, fmt.Errorf("failed to import %s: %s", path, err)
+	}
+
+	artifact := letscloud.NewSnapshotArtifact(client, map[string]interface{}{
+		"snapshot_slug":  snap.Slug,
+		"snapshot_name":  p.config.SnapshotName,
+		"location_slug":  p.config.LocationSlug,
+		"source_image":   filepath.Base(path),
+		"generated_data": source.State("generated_data"),
+	})
+	return artifact, false, false, nil
+}
+
+// diskImage returns the disk image among the files of an artifact: its only
+// file, or its only file with a disk image extension.
+func diskImage(files []string) (string, error) {
+	if len(files) == 1 {
+		return files[0], nil
+	}
+
+	var images []string
+	for _, f := range files {
+		if _, ok := formats[strings.ToLower(filepath.Ext(f))]; ok {
+			images = append(images, f)
+		}
+	}
+	if len(images) != 1 {
+		return "", fmt.Errorf("expected an artifact with a single disk image, got files %v", files)
+	}
+	return images[0], nil
+}
+
+// format returns the format of the disk image at path: the configured one,
+// the one reported by the QEMU builder, or the one its extension tells.
+func (c *Config) format(source packer.Artifact, path string) (string, error) {
+	if c.Format != "" {
+		return c.Format, nil
+	}
+	if diskType, _ := source.State("diskType").(string); diskType == "qcow2" || diskType == "raw" {
+		return diskType, nil
+	}
+	if format, ok := formats[strings.ToLower(filepath.Ext(path))]; ok {
+		return format, nil
+	}
+	return "", fmt.Errorf("unable to tell the format of %s: set `format`", path)
+}
@@ -0,0 +1,270 @@
+package letscloudimport
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/testutil"
+)
+
+// testServer returns a fake API with the mia1 location available.
+func testServer(t *testing.T) *fakeapi.Server {
+	return testutil.Server(t, "mia1")
+}
+
+// testImage writes a disk image named name to a temporary directory and
+// returns its path.
+func testImage(t *testing.T, name string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("QFI\xfb disk image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func testPostProcessor(t *testing.T, server *fakeapi.Server, overrides map[string]interface{}) *PostProcessor {
+	t.Helper()
+
+	p := &PostProcessor{pollInterval: 5 * time.Millisecond}
+	testutil.Configure(t, p, server, map[string]interface{}{"location_slug": "mia1", "snapshot_name": "imported"}, overrides)
+	return p
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessor_Configure(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "defaults",
+			raw:  map[string]interface{}{"location_slug": "mia1"},
+		},
+		{
+			name:    "no location",
+			raw:     map[string]interface{}{},
+			wantErr: "`location_slug` is required",
+		},
+		{
+			name:    "invalid format",
+			raw:     map[string]interface{}{"location_slug": "mia1", "format": "vmdk"},
+			wantErr: "`format` must be qcow2 or raw",
+		},
+		{
+			name:    "invalid timeout",
+			raw:     map[string]interface{}{"location_slug": "mia1", "import_timeout": "soon"},
+			wantErr: "invalid format for `import_timeout`",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{"api_key": fakeapi.DefaultAPIKey}
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+
+			p := new(PostProcessor)
+			err := p.Configure(raw)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if p.config.importTimeout != defaultImportTimeout {
+					t.Errorf("expected the default import timeout, got %s", p.config.importTimeout)
+				}
+				if _, ok := letscloud.SnapshotTime(p.config.SnapshotName); !ok {
+					t.Errorf("expected a timestamped default snapshot name, got %q", p.config.SnapshotName)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	server := testServer(t)
+	server.SnapshotPolls = 3
+
+	path := testImage(t, "packer-ubuntu")
+	source := &packer.MockArtifact{
+		FilesValue: []string{path},
+		StateValues: map[string]interface{}{
+			"diskType": "qcow2",
+		},
+	}
+
+	ui, _ := testutil.Ui()
+	p := testPostProcessor(t, server, nil)
+	artifact, keep, _, err := p.PostProcess(context.Background(), ui, source)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if keep {
+		t.Error("expected keep_input_artifact to decide whether the image is kept")
+	}
+
+	snaps := server.Snapshots()
+	if len(snaps) != 1 {
+		t.Fatalf("expected one snapshot, got %d", len(snaps))
+	}
+	snap := snaps[0]
+	if snap.Label != "imported" || snap.OsReference != "qcow2" || snap.Size != len("QFI\xfb disk image") || !snap.Build {
+		t.Errorf("unexpected snapshot %#v", snap)
+	}
+
+	if artifact.BuilderId() != letscloud.BuilderId {
+		t.Errorf("expected a LetsCloud artifact, got %q", artifact.BuilderId())
+	}
+	if got, want := artifact.Id(), "mia1:"+snap.Slug; got != want {
+		t.Errorf("expected ID %q, got %q", want, got)
+	}
+	if got := artifact.State("source_image"); got != "packer-ubuntu" {
+		t.Errorf("expected the image file name as source image, got %v", got)
+	}
+
+	if err := artifact.Destroy(); err != nil {
+		t.Fatalf("unexpected error destroying the artifact: %s", err)
+	}
+	if snaps := server.Snapshots(); len(snaps) != 0 {
+		t.Errorf("expected the snapshot to be deleted, got %v", snaps)
+	}
+}
+
+func TestPostProcessor_Format(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  Config
+		files   []string
+		state   map[string]interface{}
+		want    string
+		wantErr string
+	}{
+		{
+			name:   "configured",
+			config: Config{Format: "raw"},
+			files:  []string{"disk.qcow2"},
+			want:   "raw",
+		},
+		{
+			name:  "qemu disk type",
+			files: []string{"packer-ubuntu"},
+			state: map[string]interface{}{"diskType": "raw"},
+			want:  "raw",
+		},
+		{
+			name:  "extension",
+			files: []string{"packer-ubuntu.md5", "disk.QCOW2"},
+			want:  "qcow2",
+		},
+		{
+			name:    "unknown",
+			files:   []string{"packer-ubuntu"},
+			wantErr: "set `format`",
+		},
+		{
+			name:    "several images",
+			files:   []string{"disk.qcow2", "disk.raw"},
+			wantErr: "a single disk image",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := &packer.MockArtifact{FilesValue: tc.files, StateValues: tc.state}
+
+			path, err := diskImage(source.Files())
+			var got string
+			if err == nil {
+				got, err = tc.config.format(source, path)
+			}
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.want {
+				t.Errorf("expected format %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPostProcessor_PostProcessErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		setup   func(*fakeapi.Server)
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name:    "unknown location",
+			raw:     map[string]interface{}{"location_slug": "ams1"},
+			wantErr: "location",
+		},
+		{
+			name: "upload rejected",
+			setup: func(s *fakeapi.Server) {
+				s.Fail("POST /snapshots/import", fakeapi.Failure{Status: 413, Message: "image too large"})
+			},
+			wantErr: "image too large",
+		},
+		{
+			name: "snapshot not found",
+			setup: func(s *fakeapi.Server) {
+				s.Fail("GET /snapshots/{slug}", fakeapi.Failure{Status: 404, Message: "snapshot not found"})
+			},
+			wantErr: "snapshot not found",
+		},
+		{
+			name:    "timeout",
+			setup:   func(s *fakeapi.Server) { s.SnapshotPolls = 1000 },
+			raw:     map[string]interface{}{"import_timeout": "50ms"},
+			wantErr: "did not finish within the import timeout",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testServer(t)
+			if tc.setup != nil {
+				tc.setup(server)
+			}
+
+			source := &packer.MockArtifact{FilesValue: []string{testImage(t, "disk.qcow2")}}
+			ui, _ := testutil.Ui()
+			p := testPostProcessor(t, server, tc.raw)
+			artifact, _, _, err := p.PostProcess(context.Background(), ui, source)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+			if artifact != nil {
+				t.Errorf("expected no artifact, got %s", artifact.Id())
+			}
+			if snaps := server.Snapshots(); len(snaps) != 0 {
+				t.Errorf("expected no snapshot to be left behind, got %v", snaps)
+			}
+		})
+	}
+}
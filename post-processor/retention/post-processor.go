@@ -0,0 +1,207 @@
+//go:generate packer-sdc mapstructure-to-hcl2 -type Config
+
+package retention
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/v2/hcldec"
+	"github.com/hashicorp/packer-plugin-sdk/common"
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/hashicorp/packer-plugin-sdk/template/config"
+	"github.com/hashicorp/packer-plugin-sdk/template/interpolate"
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+)
+
+// Config represents the configuration for the LetsCloud retention
+// post-processor.
+type Config struct {
+	common.PackerConfig    `mapstructure:",squash"`
+	letscloud.AccessConfig `mapstructure:",squash"`
+
+	NamePrefix string `mapstructure:"name_prefix"` // Optional: Defaults to the family of the build's snapshot_name
+	KeepLatest int    `mapstructure:"keep_latest"` // Optional: Number of newest snapshots to keep
+	OlderThan  string `mapstructure:"older_than"`  // Optional: e.g. "720h"
+	DryRun     bool   `mapstructure:"dry_run"`     // Optional: Defaults to false
+
+	olderThan time.Duration
+	ctx       interpolate.Context
+}
+
+// PostProcessor deletes old snapshots of the same family as the build's.
+type PostProcessor struct {
+	config Config
+	now    func() time.Time
+}
+
+// match is a snapshot with the creation time parsed from its label.
+type match struct {
+	domains.Snapshot
+	Created time.Time
+}
+
+func (p *PostProcessor) ConfigSpec() hcldec.ObjectSpec {
+	return p.config.FlatMapstructure().HCL2Spec()
+}
+
+func (p *PostProcessor) Configure(raws ...interface{}) error {
+	err := config.Decode(&p.config, &config.DecodeOpts{
+		PluginType:         "packer.post-processor.letscloud-retention",
+		Interpolate:        true,
+		InterpolateContext: &p.config.ctx,
+	}, raws...)
+	if err != nil {
+		return err
+	}
+
+	var errs *packer.MultiError
+
+	if es := p.config.AccessConfig.Prepare(); len(es) > 0 {
+		errs = packer.MultiErrorAppend(errs, es...)
+	}
+
+	if p.config.KeepLatest < 0 {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("`keep_latest` must not be negative"))
+	}
+
+	if p.config.OlderThan != "" {
+		p.config.olderThan, err = time.ParseDuration(p.config.OlderThan)
+		if err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("invalid format for `older_than`: %s", err))
+		} else if p.config.olderThan <= 0 {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("`older_than` must be positive"))
+		}
+	}
+
+	if p.config.KeepLatest == 0 && p.config.OlderThan == "" {
+		errs = packer.MultiErrorAppend(errs, fmt.Errorf("one of `keep_latest` or `older_than` is required"))
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (p *PostProcessor) PostProcess(ctx context.Context, ui packer.Ui, source packer.Artifact) (packer.Artifact, bool, bool, error) {
+	if source.BuilderId() != letscloud.BuilderId {
+		return nil, false, false, fmt.Errorf("unknown artifact type %s: can only prune after LetsCloud builds", source.BuilderId())
+	}
+
+	current, _ := source.State("snapshot_slug").(string)
+
+	prefix := p.config.NamePrefix
+	if prefix == "" {
+		name, _ := source.State("snapshot_name").(string)
+		family, ok := letscloud.SnapshotFamily(name)
+		if !ok {
+			return nil, false, false, fmt.Errorf("unable to tell the snapshot family from %q: set `name_prefix`", name)
+		}
+		// Builds of unrelated templates share the default family; pruning it
+		// could delete their snapshots.
+		if family == letscloud.DefaultSnapshotFamily {
+			return nil, false, false, fmt.Errorf("the build used the default snapshot name, shared by every template: set `snapshot_name` or `name_prefix`")
+		}
+		prefix = family
+	}
+
+	client := p.config.NewAPIClient()
+	snapshots, err := client.Snapshots(ctx)
+	if err != nil {
+		return nil, false, false, fmt.Errorf("unable to list snapshots: %s", err)
+	}
+
+	now := time.Now
+	if p.now != nil {
+		now = p.now
+	}
+
+	expired := p.config.expired(snapshots, prefix, current, now())
+	if len(expired) == 0 {
+		ui.Say(fmt.Sprintf("No snapshot of the '%s' family to delete.", prefix))
+		return source, true, false, nil
+	}
+
+	var errs *packer.MultiError
+	for _, snap := range expired {
+		if p.config.DryRun {
+			ui.Say(fmt.Sprintf("Would delete snapshot '%s' (%s)", snap.Label, snap.Slug))
+			continue
+		}
+
+		ui.Say(fmt.Sprintf("Deleting snapshot '%s' (%s)...", snap.Label, snap.Slug))
+		if err := client.DeleteSnapshot(ctx, snap.Slug); err != nil {
+			errs = packer.MultiErrorAppend(errs, fmt.Errorf("failed to delete snapshot %s: %s", snap.Slug, err))
+		}
+	}
+
+	if errs != nil && len(errs.Errors) > 0 {
+		return nil, false, false, errs
+	}
+	return source, true, false, nil
+}
+
+// expired returns the finished snapshots of the family named by prefix (see
+// inFamily) that fall outside the retention rules, oldest first. The snapshot of the
+// current build and snapshots without a timestamp in their label are never
+// returned.
+func (c *Config) expired(snapshots []domains.Snapshot, prefix, current string, now time.Time) []match {
+	var family []match
+	for _, snap := range snapshots {
+		if !snap.Build || !c.inFamily(snap.Label, prefix) {
+			continue
+		}
+		created, ok := letscloud.SnapshotTime(snap.Label)
+		if !ok {
+			continue
+		}
+		family = append(family, match{Snapshot: snap, Created: created})
+	}
+
+	// Newest first.
+	sort.SliceStable(family, func(i, j int) bool {
+		a, b := family[i], family[j]
+		if !a.Created.Equal(b.Created) {
+			return a.Created.After(b.Created)
+		}
+		return a.Slug < b.Slug
+	})
+
+	var expired []match
+	for i, m := range family {
+		if m.Slug == current {
+			continue
+		}
+		if c.KeepLatest > 0 && i < c.KeepLatest {
+			continue
+		}
+		if c.olderThan > 0 && now.Sub(m.Created) <= c.olderThan {
+			continue
+		}
+		expired = append(expired, m)
+	}
+
+	// Oldest first, so an interrupted run leaves the newest snapshots.
+	for i, j := 0, len(expired)-1; i < j; i, j = i+1, j-1 {
+		expired[i], expired[j] = expired[j], expired[i]
+	}
+	return expired
+}
+
+// inFamily reports whether a snapshot label belongs to family. An explicit
+// `name_prefix` matches every label starting with it. A family taken from
+// the build's snapshot name must match exactly, so that pruning "web-"
+// snapshots leaves those of a "web-api-" template alone.
+func (c *Config) inFamily(label, family string) bool {
+	if c.NamePrefix != "" {
+		return strings.HasPrefix(label, family)
+	}
+	labelFamily, ok := letscloud.SnapshotFamily(label)
+	return ok && labelFamily == family
+}
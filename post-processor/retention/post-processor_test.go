@@ -0,0 +1,317 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/packer-plugin-sdk/packer"
+	"github.com/letscloud-community/letscloud-go/domains"
+
+	"github.com/letscloud-community/packer-plugin-letscloud/builder/letscloud"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/fakeapi"
+	"github.com/letscloud-community/packer-plugin-letscloud/internal/testutil"
+)
+
+var testNow = time.Unix(1718000000, 0)
+
+const day = 24 * time.Hour
+
+func label(prefix string, age time.Duration) string {
+	return fmt.Sprintf("%s%d", prefix, testNow.Add(-age).Unix())
+}
+
+// testSnapshots returns four daily builds of the "web-" family,
+// "snap-0" being the newest, and unrelated snapshots.
+func testSnapshots() []domains.Snapshot {
+	return []domains.Snapshot{
+		{Slug: "snap-2", Label: label("web-", 2*day), Build: true},
+		{Slug: "snap-0", Label: label("web-", 0), Build: true},
+		{Slug: "snap-3", Label: label("web-", 3*day), Build: true},
+		{Slug: "snap-1", Label: label("web-", day), Build: true},
+		{Slug: "building", Label: label("web-", 4*day)},
+		{Slug: "manual", Label: "web-manual", Build: true},
+		{Slug: "other", Label: label("hardened-", 10*day), Build: true},
+		{Slug: "api", Label: label("web-api-", 10*day), Build: true},
+	}
+}
+
+func testServer(t *testing.T) *fakeapi.Server {
+	server := testutil.Server(t)
+	for _, snap := range testSnapshots() {
+		// The fake only holds finished snapshots.
+		if snap.Build {
+			server.AddSnapshot(snap)
+		}
+	}
+	return server
+}
+
+func testSource() packer.Artifact {
+	return letscloud.NewArtifact(map[string]interface{}{
+		"snapshot_slug": "snap-0",
+		"snapshot_name": label("web-", 0),
+		"location_slug": "mia1",
+	})
+}
+
+func testPostProcessor(t *testing.T, server *fakeapi.Server, overrides map[string]interface{}) *PostProcessor {
+	t.Helper()
+
+	p := &PostProcessor{now: func() time.Time { return testNow }}
+	testutil.Configure(t, p, server, overrides)
+	return p
+}
+
+func slugs(snapshots []domains.Snapshot) []string {
+	out := make([]string, len(snapshots))
+	for i, snap := range snapshots {
+		out[i] = snap.Slug
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestPostProcessor_ImplementsPostProcessor(t *testing.T) {
+	var _ packer.PostProcessor = new(PostProcessor)
+}
+
+func TestPostProcessor_Configure(t *testing.T) {
+	cases := []struct {
+		name    string
+		raw     map[string]interface{}
+		wantErr string
+	}{
+		{
+			name: "keep latest",
+			raw:  map[string]interface{}{"keep_latest": 3},
+		},
+		{
+			name: "older than",
+			raw:  map[string]interface{}{"older_than": "720h"},
+		},
+		{
+			name:    "no rule",
+			raw:     map[string]interface{}{"dry_run": true},
+			wantErr: "one of `keep_latest` or `older_than` is required",
+		},
+		{
+			name:    "negative keep latest",
+			raw:     map[string]interface{}{"keep_latest": -1},
+			wantErr: "`keep_latest` must not be negative",
+		},
+		{
+			name:    "invalid older than",
+			raw:     map[string]interface{}{"older_than": "a month"},
+			wantErr: "invalid format for `older_than`",
+		},
+		{
+			name:    "negative older than",
+			raw:     map[string]interface{}{"older_than": "-1h"},
+			wantErr: "`older_than` must be positive",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			raw := map[string]interface{}{"api_key": fakeapi.DefaultAPIKey}
+			for k, v := range tc.raw {
+				raw[k] = v
+			}
+
+			err := new(PostProcessor).Configure(raw)
+			if tc.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestConfig_Expired(t *testing.T) {
+	cases := []struct {
+		name    string
+		config  Config
+		prefix  string
+		current string
+		want    []string
+	}{
+		{
+			name:   "keep latest",
+			config: Config{KeepLatest: 2},
+			prefix: "web-",
+			want:   []string{"snap-3", "snap-2"},
+		},
+		{
+			name:   "older than",
+			config: Config{olderThan: 36 * time.Hour},
+			prefix: "web-",
+			want:   []string{"snap-3", "snap-2"},
+		},
+		{
+			name:   "both rules",
+			config: Config{KeepLatest: 3, olderThan: 36 * time.Hour},
+			prefix: "web-",
+			want:   []string{"snap-3"},
+		},
+		{
+			name:    "current build is kept",
+			config:  Config{KeepLatest: 1},
+			prefix:  "web-",
+			current: "snap-3",
+			want:    []string{"snap-2", "snap-1"},
+		},
+		{
+			name:   "explicit prefix includes longer families",
+			config: Config{KeepLatest: 2, NamePrefix: "web-"},
+			prefix: "web-",
+			want:   []string{"api", "snap-3", "snap-2"},
+		},
+		{
+			name:   "other family",
+			config: Config{KeepLatest: 1},
+			prefix: "hardened-",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got []string
+			for _, m := range tc.config.expired(testSnapshots(), tc.prefix, tc.current, testNow) {
+				got = append(got, m.Slug)
+			}
+			if !slices.Equal(got, tc.want) {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestPostProcessor_PostProcess(t *testing.T) {
+	server := testServer(t)
+
+	ui, out := testutil.Ui()
+	p := testPostProcessor(t, server, map[string]interface{}{"keep_latest": 2})
+	source := testSource()
+	artifact, keep, _, err := p.PostProcess(context.Background(), ui, source)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if artifact != source || !keep {
+		t.Errorf("expected the source artifact to be passed through and kept")
+	}
+
+	want := []string{"api", "manual", "other", "snap-0", "snap-1"}
+	if got := slugs(server.Snapshots()); !slices.Equal(got, want) {
+		t.Errorf("expected snapshots %v to remain, got %v", want, got)
+	}
+	for _, slug := range []string{"snap-2", "snap-3"} {
+		if !strings.Contains(out.String(), slug) {
+			t.Errorf("expected the deletion of %s to be reported\n%s", slug, out)
+		}
+	}
+}
+
+func TestPostProcessor_DryRun(t *testing.T) {
+	server := testServer(t)
+
+	ui, out := testutil.Ui()
+	p := testPostProcessor(t, server, map[string]interface{}{"keep_latest": 2, "dry_run": true})
+	if _, _, _, err := p.PostProcess(context.Background(), ui, testSource()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if got := server.Calls("DELETE /snapshots/{slug}"); got != 0 {
+		t.Errorf("expected no deletion in dry-run mode, got %d", got)
+	}
+	for _, slug := range []string{"snap-2", "snap-3"} {
+		if !strings.Contains(out.String(), "Would delete snapshot") || !strings.Contains(out.String(), slug) {
+			t.Errorf("expected %s to be reported as deleted\n%s", slug, out)
+		}
+	}
+}
+
+func TestPostProcessor_PostProcessErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		source  packer.Artifact
+		setup   func(*fakeapi.Server)
+		wantErr string
+	}{
+		{
+			name:    "other builder",
+			source:  &packer.MockArtifact{BuilderIdValue: "packer.other"},
+			wantErr: "can only prune after LetsCloud builds",
+		},
+		{
+			name:    "no family",
+			source:  letscloud.NewArtifact(map[string]interface{}{"snapshot_slug": "golden", "snapshot_name": "golden"}),
+			wantErr: "set `name_prefix`",
+		},
+		{
+			name:    "default name",
+			source:  letscloud.NewArtifact(map[string]interface{}{"snapshot_slug": "snap-9", "snapshot_name": label("packer-snapshot-", 0)}),
+			wantErr: "the build used the default snapshot name",
+		},
+		{
+			name: "list fails",
+			setup: func(s *fakeapi.Server) {
+				s.Fail("GET /snapshots", fakeapi.Failure{Status: 403, Message: "forbidden"})
+			},
+			wantErr: "unable to list snapshots",
+		},
+		{
+			name: "delete fails",
+			setup: func(s *fakeapi.Server) {
+				s.Fail("DELETE /snapshots/{slug}", fakeapi.Failure{Status: 409, Message: "snapshot in use"})
+			},
+			wantErr: "snapshot in use",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			server := testServer(t)
+			if tc.setup != nil {
+				tc.setup(server)
+			}
+			source := tc.source
+			if source == nil {
+				source = testSource()
+			}
+
+			ui, _ := testutil.Ui()
+			p := testPostProcessor(t, server, map[string]interface{}{"keep_latest": 1})
+			_, _, _, err := p.PostProcess(context.Background(), ui, source)
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Fatalf("expected an error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestPostProcessor_NamePrefix(t *testing.T) {
+	server := testServer(t)
+
+	ui, _ := testutil.Ui()
+	p := testPostProcessor(t, server, map[string]interface{}{"name_prefix": "hardened-", "older_than": "168h"})
+	source := letscloud.NewArtifact(map[string]interface{}{"instance_identifier": "inst-1"})
+	if _, _, _, err := p.PostProcess(context.Background(), ui, source); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if slices.Contains(slugs(server.Snapshots()), "other") {
+		t.Error("expected the old hardened snapshot to be deleted")
+	}
+	if got := server.Calls("DELETE /snapshots/{slug}"); got != 1 {
+		t.Errorf("expected a single deletion, got %d", got)
+	}
+}